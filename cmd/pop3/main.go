@@ -0,0 +1,248 @@
+// Command pop3 lists, fetches and deletes messages in a POP3 maildrop.
+//
+// Usage:
+//
+//	pop3 [flags] stat
+//	pop3 [flags] list
+//	pop3 [flags] fetch [-maildir dir] [-delete] [msg ...]
+//	pop3 [flags] delete msg ...
+//
+// The password and OAuth 2.0 access token may also be given in the
+// POP3_PASSWORD and POP3_OAUTH_TOKEN environment variables.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/emvenci/go-pop3"
+)
+
+var (
+	addr       = flag.String("addr", "", "server `host:port`")
+	user       = flag.String("user", "", "username")
+	pass       = flag.String("pass", "", "password (default $POP3_PASSWORD)")
+	oauthToken = flag.String("oauth-token", "", "OAuth 2.0 access token, used instead of the password (default $POP3_OAUTH_TOKEN)")
+	useTLS     = flag.Bool("tls", false, "connect with TLS")
+	startTLS   = flag.Bool("starttls", false, "upgrade the connection with STLS")
+	insecure   = flag.Bool("insecure", false, "do not verify the server certificate")
+	trace      = flag.Bool("trace", false, "log the protocol exchange to stderr")
+)
+
+// Flags of the fetch subcommand, parsed before connecting.
+var (
+	fetchFlags = flag.NewFlagSet("fetch", flag.ExitOnError)
+	maildir    = fetchFlags.String("maildir", "", "deliver messages into the Maildir `dir` instead of printing them")
+	remove     = fetchFlags.Bool("delete", false, "delete messages after fetching them")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: pop3 [flags] stat|list|fetch|delete [args]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *addr == "" || flag.NArg() == 0 {
+		usage()
+	}
+	args := flag.Args()
+	switch args[0] {
+	case "stat", "list":
+	case "fetch":
+		fetchFlags.Parse(args[1:])
+	case "delete":
+		if len(args) == 1 {
+			usage()
+		}
+	default:
+		usage()
+	}
+	// The secrets are not flag defaults, so that usage does not print them.
+	if *pass == "" {
+		*pass = os.Getenv("POP3_PASSWORD")
+	}
+	if *oauthToken == "" {
+		*oauthToken = os.Getenv("POP3_OAUTH_TOKEN")
+	}
+
+	c, err := connect()
+	if err != nil {
+		fatal(err)
+	}
+	switch args[0] {
+	case "stat":
+		err = stat(c)
+	case "list":
+		err = list(c)
+	case "fetch":
+		err = fetch(c, fetchFlags.Args())
+	case "delete":
+		err = del(c, args[1:])
+	}
+	if err != nil {
+		c.Close()
+		fatal(err)
+	}
+	if err = c.Quit(); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "pop3: %s\n", err)
+	os.Exit(1)
+}
+
+func connect() (*pop3.Client, error) {
+	var opts []pop3.Option
+	if *trace {
+		opts = append(opts, pop3.WithTrace(os.Stderr))
+	}
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{ServerName: host, InsecureSkipVerify: *insecure}
+
+	var c *pop3.Client
+	if *useTLS {
+		c, err = pop3.DialTLS(*addr, config, opts...)
+	} else {
+		c, err = pop3.Dial(*addr, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if *startTLS {
+		if err = c.StartTLS(config); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if *oauthToken != "" {
+		err = c.XOAuth2(*user, *oauthToken)
+	} else {
+		err = c.Auth(*user, *pass)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func stat(c *pop3.Client) error {
+	count, size, err := c.Stat()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d messages (%d octets)\n", count, size)
+	return nil
+}
+
+func list(c *pop3.Client) error {
+	msgs, err := c.ListMessages()
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		fmt.Printf("%d\t%d\t%s\n", m.Seq, m.Size, m.UID)
+	}
+	return nil
+}
+
+func fetch(c *pop3.Client, args []string) error {
+	msgs, err := messages(c, args)
+	if err != nil {
+		return err
+	}
+	if *maildir != "" {
+		for _, sub := range []string{"tmp", "new", "cur"} {
+			if err = os.MkdirAll(filepath.Join(*maildir, sub), 0700); err != nil {
+				return err
+			}
+		}
+	}
+	for _, msg := range msgs {
+		text, err := c.Retr(msg)
+		if err != nil {
+			return err
+		}
+		if *maildir != "" {
+			err = deliver(*maildir, text)
+		} else {
+			_, err = io.WriteString(os.Stdout, text+"\n")
+		}
+		if err != nil {
+			return err
+		}
+		if *remove {
+			if err = c.Dele(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// messages returns the message numbers given on the command line, or all
+// messages in the maildrop if there are none.
+func messages(c *pop3.Client, args []string) ([]int, error) {
+	var msgs []int
+	if len(args) == 0 {
+		list, err := c.ListMessages()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range list {
+			msgs = append(msgs, m.Seq)
+		}
+		return msgs, nil
+	}
+	for _, arg := range args {
+		msg, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message number %q", arg)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+var deliveries int
+
+// deliver stores a message in a Maildir, writing it to tmp and moving it to
+// new once complete.
+func deliver(dir, text string) error {
+	hostname, _ := os.Hostname()
+	deliveries++
+	name := fmt.Sprintf("%d.%d_%d.%s", time.Now().Unix(), os.Getpid(), deliveries, hostname)
+	tmp := filepath.Join(dir, "tmp", name)
+	if err := os.WriteFile(tmp, []byte(text+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, "new", name))
+}
+
+func del(c *pop3.Client, args []string) error {
+	msgs, err := messages(c, args)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err = c.Dele(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
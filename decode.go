@@ -0,0 +1,148 @@
+package pop3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// A DecodedMessage is a message with its MIME structure taken apart, as
+// returned by RetrDecoded.
+type DecodedMessage struct {
+	// Header is the top-level header of the message. Its values are not
+	// decoded; use mime.WordDecoder for encoded words.
+	Header mail.Header
+	// Text and HTML are the text/plain and text/html bodies, converted to
+	// UTF-8. If the message has several inline parts of the same type, they
+	// are concatenated.
+	Text string
+	HTML string
+	// Attachments describes all other parts.
+	Attachments []Attachment
+}
+
+// An Attachment describes a part of a message which is not an inline text
+// or HTML body.
+type Attachment struct {
+	// Filename is the name given in the Content-Disposition or Content-Type
+	// header, if any.
+	Filename string
+	// ContentType is the media type of the part, such as "application/pdf".
+	ContentType string
+	// Size is the size of the part in octets, after transfer decoding.
+	Size int64
+}
+
+// RetrDecoded downloads the given message like Retr and decodes it: the
+// quoted-printable and base64 transfer encodings are undone, and text parts
+// are converted to UTF-8 from their declared charset. Parts in an unknown
+// charset are returned as is.
+func (c *Client) RetrDecoded(msg int) (*DecodedMessage, error) {
+	text, err := c.Retr(msg)
+	if err != nil {
+		return nil, err
+	}
+	m, err := mail.ReadMessage(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	d := &DecodedMessage{Header: m.Header}
+	if err = d.walk(textproto.MIMEHeader(m.Header), m.Body); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+var wordDecoder = mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// walk decodes the part with the given header and body, descending into
+// multipart bodies.
+func (d *DecodedMessage) walk(header textproto.MIMEHeader, body io.Reader) error {
+	mediatype, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediatype, params = "text/plain", nil
+	}
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	if strings.HasPrefix(mediatype, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err = d.walk(p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dparams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if s, err := wordDecoder.DecodeHeader(filename); err == nil {
+		filename = s
+	}
+	inline := disposition != "attachment" && filename == ""
+	if inline && (mediatype == "text/plain" || mediatype == "text/html") {
+		text, err := decodeCharset(params["charset"], body)
+		if err != nil {
+			return err
+		}
+		if mediatype == "text/plain" {
+			d.Text += text
+		} else {
+			d.HTML += text
+		}
+		return nil
+	}
+
+	n, err := io.Copy(io.Discard, body)
+	if err != nil {
+		return err
+	}
+	d.Attachments = append(d.Attachments, Attachment{
+		Filename:    filename,
+		ContentType: mediatype,
+		Size:        n,
+	})
+	return nil
+}
+
+// decodeCharset reads r, converting it from the given charset to UTF-8.
+func decodeCharset(label string, r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if label == "" {
+		return string(b), nil
+	}
+	cr, err := charset.NewReaderLabel(label, bytes.NewReader(b))
+	if err != nil {
+		return string(b), nil
+	}
+	u, err := io.ReadAll(cr)
+	if err != nil {
+		return string(b), nil
+	}
+	return string(u), nil
+}
@@ -0,0 +1,58 @@
+package pop3
+
+import "testing"
+
+func TestRetrDecoded(t *testing.T) {
+	c, _ := fakeSession(t, decodeServer)
+	m, err := c.RetrDecoded(1)
+	if err != nil {
+		t.Fatalf("RetrDecoded failed: %s", err)
+	}
+	if m.Header.Get("Subject") != "report" {
+		t.Fatalf("Got header %v", m.Header)
+	}
+	if m.Text != "Café ouvert =" {
+		t.Fatalf("Got text %q", m.Text)
+	}
+	if m.HTML != "<p>Café</p>" {
+		t.Fatalf("Got HTML %q", m.HTML)
+	}
+	if len(m.Attachments) != 1 {
+		t.Fatalf("Got %d attachments, expected 1", len(m.Attachments))
+	}
+	if a := m.Attachments[0]; a.Filename != "résumé.pdf" || a.ContentType != "application/pdf" || a.Size != 5 {
+		t.Fatalf("Got attachment %+v", a)
+	}
+}
+
+var decodeServer = `+OK good morning
++OK send PASS
++OK welcome
++OK message follows
+Subject: report
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Caf=E9 ouvert =3D
+--inner
+Content-Type: text/html; charset=utf-8
+Content-Transfer-Encoding: base64
+
+PHA+Q2Fmw6k8L3A+
+--inner--
+--outer
+Content-Type: application/pdf
+Content-Disposition: attachment; filename="=?utf-8?q?r=C3=A9sum=C3=A9.pdf?="
+Content-Transfer-Encoding: base64
+
+JVBERi0=
+--outer--
+.
+`
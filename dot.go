@@ -0,0 +1,173 @@
+package pop3
+
+import (
+	"bufio"
+	"io"
+)
+
+// A DotReader reads a block of lines terminated by a line consisting of a
+// single dot, as sent in multi-line responses. It removes the byte-stuffing
+// of lines starting with a dot and converts CRLF line endings to LF. The
+// terminating line is consumed but not returned.
+//
+// Read returns io.EOF at the end of the block, and io.ErrUnexpectedEOF if
+// the underlying reader ends before the terminating line.
+type DotReader struct {
+	r     *bufio.Reader
+	state int
+	// crlf rejects lines terminated by LF alone with ErrInvalidResponse.
+	crlf bool
+}
+
+const (
+	dotBeginLine = iota // at the beginning of a line
+	dotDot              // read a dot at the beginning of a line
+	dotDotCR            // read a dot and CR at the beginning of a line
+	dotCR               // read CR, possibly ending a line
+	dotData             // in the middle of a line
+	dotEOF              // read the terminating line
+)
+
+// NewDotReader returns a DotReader reading the block at the start of r.
+// Nothing beyond the terminating line is read from r.
+func NewDotReader(r *bufio.Reader) *DotReader {
+	return &DotReader{r: r}
+}
+
+func (d *DotReader) Read(p []byte) (n int, err error) {
+	for n < len(p) && d.state != dotEOF {
+		var b byte
+		b, err = d.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		if b == '\n' && d.crlf && d.state != dotCR && d.state != dotDotCR {
+			return n, ErrInvalidResponse
+		}
+		switch d.state {
+		case dotBeginLine:
+			if b == '.' {
+				d.state = dotDot
+				continue
+			}
+			if b == '\r' {
+				d.state = dotCR
+				continue
+			}
+			d.state = dotData
+		case dotDot:
+			if b == '\r' {
+				d.state = dotDotCR
+				continue
+			}
+			if b == '\n' {
+				d.state = dotEOF
+				continue
+			}
+			d.state = dotData
+		case dotDotCR:
+			if b == '\n' {
+				d.state = dotEOF
+				continue
+			}
+			// A stuffed line starting with CR: drop the dot, keep the CR.
+			d.r.UnreadByte()
+			b = '\r'
+			d.state = dotData
+		case dotCR:
+			if b == '\n' {
+				d.state = dotBeginLine
+				break
+			}
+			// A lone CR is data.
+			d.r.UnreadByte()
+			b = '\r'
+			d.state = dotData
+		case dotData:
+			if b == '\r' {
+				d.state = dotCR
+				continue
+			}
+			if b == '\n' {
+				d.state = dotBeginLine
+			}
+		}
+		p[n] = b
+		n++
+	}
+	if d.state == dotEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close discards the rest of the block, so that the underlying reader is
+// positioned after the terminating line.
+func (d *DotReader) Close() error {
+	_, err := io.Copy(io.Discard, d)
+	return err
+}
+
+// A DotWriter writes a block of lines in the form of a multi-line response.
+// It converts LF line endings to CRLF and stuffs lines starting with a dot
+// with another one. Close terminates the block.
+type DotWriter struct {
+	w     *bufio.Writer
+	state int
+}
+
+// NewDotWriter returns a DotWriter writing a block to w.
+func NewDotWriter(w io.Writer) *DotWriter {
+	return &DotWriter{w: bufio.NewWriter(w)}
+}
+
+func (d *DotWriter) Write(p []byte) (n int, err error) {
+	for n < len(p) {
+		b := p[n]
+		switch d.state {
+		case dotBeginLine:
+			d.state = dotData
+			if b == '.' {
+				d.w.WriteByte('.')
+			}
+			fallthrough
+		case dotData:
+			if b == '\r' {
+				d.state = dotCR
+			}
+			if b == '\n' {
+				d.w.WriteByte('\r')
+				d.state = dotBeginLine
+			}
+		case dotCR:
+			switch b {
+			case '\n':
+				d.state = dotBeginLine
+			case '\r':
+			default:
+				d.state = dotData
+			}
+		}
+		if err = d.w.WriteByte(b); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Close ends the current line, if it is unterminated, writes the terminating
+// line and flushes the block. It does not close the underlying writer.
+func (d *DotWriter) Close() error {
+	switch d.state {
+	case dotCR:
+		d.w.WriteByte('\n')
+	case dotData:
+		d.w.WriteString("\r\n")
+	}
+	d.w.WriteString(".\r\n")
+	return d.w.Flush()
+}
@@ -0,0 +1,66 @@
+package pop3
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+var dotTests = []struct {
+	encoded, decoded string
+}{
+	{".\r\n", ""},
+	{"line\r\n.\r\n", "line\n"},
+	{"one\r\ntwo\r\n\r\n.\r\n", "one\ntwo\n\n"},
+	{"..dot\r\n...\r\n.\r\n", ".dot\n..\n"},
+	{"lone\rcr\r\n.\r\n", "lone\rcr\n"},
+	{"a.\r\n .\r\n.\r\n", "a.\n .\n"},
+}
+
+func TestDotReader(t *testing.T) {
+	for _, tt := range dotTests {
+		r := bufio.NewReader(strings.NewReader(tt.encoded + "+OK next"))
+		got, err := io.ReadAll(NewDotReader(r))
+		if err != nil {
+			t.Fatalf("Reading %q failed: %s", tt.encoded, err)
+		}
+		if string(got) != tt.decoded {
+			t.Fatalf("Read %q as %q, expected %q", tt.encoded, got, tt.decoded)
+		}
+		rest, _ := io.ReadAll(r)
+		if string(rest) != "+OK next" {
+			t.Fatalf("Reading %q left %q", tt.encoded, rest)
+		}
+	}
+
+	r := bufio.NewReader(strings.NewReader("unterminated\r\n"))
+	if _, err := io.ReadAll(NewDotReader(r)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Got %v, expected io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestDotWriter(t *testing.T) {
+	for _, tt := range dotTests {
+		var buf bytes.Buffer
+		w := NewDotWriter(&buf)
+		if _, err := io.WriteString(w, tt.decoded); err != nil {
+			t.Fatalf("Writing %q failed: %s", tt.decoded, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Closing after %q failed: %s", tt.decoded, err)
+		}
+		if buf.String() != tt.encoded {
+			t.Fatalf("Wrote %q as %q, expected %q", tt.decoded, buf.String(), tt.encoded)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := NewDotWriter(&buf)
+	io.WriteString(w, ".no newline")
+	w.Close()
+	if buf.String() != "..no newline\r\n.\r\n" {
+		t.Fatalf("Got %q", buf.String())
+	}
+}
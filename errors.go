@@ -0,0 +1,88 @@
+package pop3
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrClosed is returned for commands issued after the connection has
+	// been closed, by Quit, Close or a failure.
+	ErrClosed = errors.New("Connection closed")
+	// ErrInvalidResponse is returned when the server sends a response which
+	// does not conform to the protocol.
+	ErrInvalidResponse = errors.New("Invalid server response")
+	// ErrLineTooLong is returned when the server sends a line longer than
+	// the limit set with WithMaxLineLength.
+	ErrLineTooLong = errors.New("Line too long")
+	// ErrMessageTooLarge is returned when a multi-line response exceeds the
+	// limit set with WithMaxMessageSize.
+	ErrMessageTooLarge = errors.New("Message too large")
+	// ErrNoUIDL is returned by operations which tell messages apart by
+	// unique-id when the server does not send any.
+	ErrNoUIDL = errors.New("Server does not support UIDL")
+	// ErrInvalidArgument is returned, without sending anything, for command
+	// arguments containing CR or LF, which would inject further commands.
+	ErrInvalidArgument = errors.New("Argument contains CR or LF")
+)
+
+// An Error is a negative (-ERR) response from the server.
+type Error struct {
+	// Code is the extended response code at the start of the response, as
+	// defined in RFC 2449, without its brackets; for example "AUTH" or
+	// "SYS/TEMP". It is empty if the server sent none.
+	Code string
+	// Text is the human-readable remainder of the response, which may
+	// contain UTF-8 once UTF8 is in effect. Octets which are not UTF-8 are
+	// replaced by U+FFFD.
+	Text string
+	// Lang is the language tag selected with SetLanguage when the response
+	// was received, and empty if the server's default language was in use.
+	Lang string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return e.Text
+	}
+	return "[" + e.Code + "] " + e.Text
+}
+
+// newError returns the Error for the text of a negative response.
+func (c *Client) newError(text string) *Error {
+	text = strings.ToValidUTF8(text, "\uFFFD")
+	e := &Error{Text: text, Lang: c.lang}
+	if strings.HasPrefix(text, "[") {
+		if i := strings.IndexByte(text, ']'); i > 0 {
+			e.Code = text[1:i]
+			e.Text = strings.TrimLeft(text[i+1:], " ")
+		}
+	}
+	return e
+}
+
+// A ConnError is returned when reading from or writing to the connection
+// fails. Unlike after an Error, the session cannot be used any further: the
+// connection is closed and the Client is in StateClosed.
+type ConnError struct {
+	Err error
+}
+
+func (e *ConnError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnError) Unwrap() error {
+	return e.Err
+}
+
+// A StateError is returned for commands which are not valid in the current
+// state of the session. No command is sent to the server.
+type StateError struct {
+	Cmd   string
+	State State
+}
+
+func (e *StateError) Error() string {
+	return e.Cmd + " is not valid in the " + e.State.String() + " state"
+}
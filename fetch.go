@@ -0,0 +1,61 @@
+package pop3
+
+// FetchOptions controls which messages FetchNew retrieves.
+type FetchOptions struct {
+	// Seen reports whether the message with the given unique-id has been
+	// fetched before. If Seen is nil, every message is new. Otherwise the
+	// server must support UIDL, or FetchNew fails with ErrNoUIDL.
+	Seen func(uid string) bool
+	// MaxSize is the size in octets, as reported by LIST, above which new
+	// messages are skipped rather than retrieved. Zero means no limit.
+	MaxSize int64
+}
+
+// A FetchReport lists the new messages handled by FetchNew.
+type FetchReport struct {
+	// Fetched holds the messages retrieved and passed to the callback.
+	Fetched []MessageInfo
+	// Skipped holds the messages larger than FetchOptions.MaxSize, which
+	// may be fetched later, for example over a faster connection.
+	Skipped []MessageInfo
+}
+
+// FetchNew retrieves the messages not seen before, as determined by
+// opts.Seen, and calls fn with each of them in turn. Messages above
+// opts.MaxSize are not retrieved, but listed as skipped in the report, so
+// that large downloads can be deferred on metered or slow connections.
+//
+// If fn or a command fails, FetchNew stops and returns the error along with
+// the report of the messages handled so far.
+func (c *Client) FetchNew(opts FetchOptions, fn func(m MessageInfo, text string) error) (*FetchReport, error) {
+	msgs, err := c.ListMessages()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Seen != nil {
+		for _, m := range msgs {
+			if m.UID == "" {
+				return nil, ErrNoUIDL
+			}
+		}
+	}
+	report := &FetchReport{}
+	for _, m := range msgs {
+		if opts.Seen != nil && opts.Seen(m.UID) {
+			continue
+		}
+		if opts.MaxSize > 0 && m.Size > opts.MaxSize {
+			report.Skipped = append(report.Skipped, m)
+			continue
+		}
+		text, err := c.Retr(m.Seq)
+		if err != nil {
+			return report, err
+		}
+		if err = fn(m, text); err != nil {
+			return report, err
+		}
+		report.Fetched = append(report.Fetched, m)
+	}
+	return report, nil
+}
@@ -0,0 +1,57 @@
+package pop3
+
+import "testing"
+
+func TestFetchNew(t *testing.T) {
+	c, _ := fakeSession(t, fetchServer)
+	var texts []string
+	report, err := c.FetchNew(FetchOptions{
+		Seen:    func(uid string) bool { return uid == "a" },
+		MaxSize: 1000,
+	}, func(m MessageInfo, text string) error {
+		texts = append(texts, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FetchNew failed: %s", err)
+	}
+	if len(report.Fetched) != 1 || report.Fetched[0].UID != "c" {
+		t.Fatalf("Fetched %v, expected message c", report.Fetched)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].UID != "b" {
+		t.Fatalf("Skipped %v, expected message b", report.Skipped)
+	}
+	if len(texts) != 1 || texts[0] != "Subject: small" {
+		t.Fatalf("Got %q", texts)
+	}
+}
+
+func TestFetchNewNoUIDL(t *testing.T) {
+	c, _ := fakeSession(t, "+OK good morning\n+OK send PASS\n+OK welcome\n"+
+		"+OK 1 messages\n1 120\n.\n-ERR unknown command\n")
+	_, err := c.FetchNew(FetchOptions{Seen: func(string) bool { return true }}, func(MessageInfo, string) error {
+		t.Fatal("FetchNew retrieved a message without unique-id")
+		return nil
+	})
+	if err != ErrNoUIDL {
+		t.Fatalf("FetchNew returned %v, expected ErrNoUIDL", err)
+	}
+}
+
+var fetchServer = `+OK good morning
++OK send PASS
++OK welcome
++OK 3 messages
+1 120
+2 5000000
+3 140
+.
++OK
+1 a
+2 b
+3 c
+.
++OK message follows
+Subject: small
+.
+`
@@ -0,0 +1,115 @@
+package pop3
+
+import (
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// MessageHeader holds selected header fields of a message in the maildrop.
+type MessageHeader struct {
+	MessageInfo
+	Header mail.Header
+}
+
+// ScanHeaders returns the given header fields, such as Subject, From and
+// Date, of every message in the maildrop, for building message lists without
+// downloading whole messages. Fields missing from a message are absent from
+// its Header. If fields is empty, all header fields are returned.
+//
+// Servers supporting the XTND XLST extension of Qpopper send a single
+// listing per field. Other servers are sent TOP n 0 for every message, and
+// fields not asked for are dropped.
+func (c *Client) ScanHeaders(fields []string) (headers []MessageHeader, err error) {
+	if err = checkArgs(fields...); err != nil {
+		return nil, err
+	}
+	msgs, err := c.ListMessages()
+	if err != nil {
+		return nil, err
+	}
+	headers = make([]MessageHeader, len(msgs))
+	index := make(map[int]mail.Header, len(msgs))
+	for i, m := range msgs {
+		headers[i] = MessageHeader{MessageInfo: m, Header: make(mail.Header)}
+		index[m.Seq] = headers[i].Header
+	}
+
+	if len(fields) > 0 {
+		err = c.xlst(fields, index)
+		if _, ok := err.(*Error); !ok {
+			return headers, err
+		}
+	}
+
+	want := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		want[textproto.CanonicalMIMEHeaderKey(f)] = true
+	}
+	for _, h := range headers {
+		text, err := c.Top(h.Seq, 0)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := mail.ReadMessage(strings.NewReader(text + "\n\n"))
+		if err != nil {
+			continue
+		}
+		for k, v := range msg.Header {
+			if len(want) == 0 || want[k] {
+				h.Header[k] = v
+			}
+		}
+	}
+	return headers, nil
+}
+
+// xlst fills index with the given fields using XTND XLST, whose listings
+// consist of lines like "1 Subject: hello". Negative responses are returned
+// as is, so that the caller may fall back to TOP.
+func (c *Client) xlst(fields []string, index map[int]mail.Header) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, field := range fields {
+		_, err := c.cmd("XTND XLST %s", field)
+		if err != nil {
+			return err
+		}
+		lines, err := c.readLines(nil)
+		if err != nil {
+			return err
+		}
+		for _, l := range lines {
+			msg, value, err := parseXlstLine(l)
+			if err != nil {
+				if c.quirks&QuirkListFields != 0 {
+					continue
+				}
+				return err
+			}
+			k := textproto.CanonicalMIMEHeaderKey(field)
+			if h := index[msg]; h != nil && value != "" {
+				h[k] = append(h[k], value)
+			}
+		}
+	}
+	return nil
+}
+
+// parseXlstLine parses a line of an XTND XLST listing into the message
+// number and the value of the field.
+func parseXlstLine(l string) (msg int, value string, err error) {
+	fs := strings.SplitN(l, " ", 2)
+	if len(fs) != 2 {
+		return 0, "", ErrInvalidResponse
+	}
+	msg, err = parseMsg(fs[0])
+	if err != nil {
+		return 0, "", err
+	}
+	i := strings.IndexByte(fs[1], ':')
+	if i < 0 {
+		return 0, "", ErrInvalidResponse
+	}
+	return msg, strings.TrimSpace(fs[1][i+1:]), nil
+}
@@ -0,0 +1,86 @@
+package pop3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanHeaders(t *testing.T) {
+	c, cmdbuf := fakeSession(t, xlstServer)
+	cmdbuf.Reset()
+	headers, err := c.ScanHeaders([]string{"subject", "From"})
+	if err != nil {
+		t.Fatalf("ScanHeaders failed: %s", err)
+	}
+	if len(headers) != 2 {
+		t.Fatalf("Got %d messages, expected 2", len(headers))
+	}
+	if h := headers[0]; h.UID != "a" || h.Header.Get("Subject") != "hello" || h.Header.Get("From") != "alice@example.com" {
+		t.Fatalf("Got %+v", h)
+	}
+	if h := headers[1]; h.Header.Get("Subject") != "" || h.Header.Get("From") != "bob@example.com" {
+		t.Fatalf("Got %+v", h)
+	}
+	expected := "LIST\r\nUIDL\r\nXTND XLST subject\r\nXTND XLST From\r\n"
+	if cmdbuf.String() != expected {
+		t.Fatalf("Sent %q, expected %q", cmdbuf.String(), expected)
+	}
+
+	if _, err = c.ScanHeaders([]string{"Subject\r\nDELE 1"}); err != ErrInvalidArgument {
+		t.Fatalf("ScanHeaders returned %v, expected ErrInvalidArgument", err)
+	}
+
+	c, cmdbuf = fakeSession(t, topServer)
+	cmdbuf.Reset()
+	headers, err = c.ScanHeaders([]string{"Subject"})
+	if err != nil {
+		t.Fatalf("ScanHeaders failed: %s", err)
+	}
+	if len(headers[0].Header) != 1 || headers[0].Header.Get("Subject") != "hello" || len(headers[1].Header) != 0 {
+		t.Fatalf("Got %+v", headers)
+	}
+	if !strings.HasSuffix(cmdbuf.String(), "XTND XLST Subject\r\nTOP 1 0\r\nTOP 2 0\r\n") {
+		t.Fatalf("Sent %q", cmdbuf.String())
+	}
+}
+
+var xlstServer = `+OK good morning
++OK send PASS
++OK welcome
++OK 2 messages
+1 120
+2 130
+.
++OK
+1 a
+2 b
+.
++OK header list follows
+1 Subject: hello
+2 Subject:
+.
++OK header list follows
+1 From: alice@example.com
+2 From: bob@example.com
+.
+`
+
+var topServer = `+OK good morning
++OK send PASS
++OK welcome
++OK 2 messages
+1 120
+2 130
+.
+-ERR not supported
+-ERR unknown command
++OK
+Subject: hello
+From: alice@example.com
+
+.
++OK
+From: bob@example.com
+
+.
+`
@@ -0,0 +1,98 @@
+package pop3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseStat parses a drop listing, the text of a positive response to STAT
+// following "+OK ", such as "2 320". Anything after the size is ignored.
+func ParseStat(line string) (count int, size int64, err error) {
+	fs := strings.Fields(line)
+	if len(fs) < 2 {
+		return 0, 0, ErrInvalidResponse
+	}
+	n, err := parseNumber(fs[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err = parseNumber(fs[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(n), size, nil
+}
+
+// ParseListLine parses a scan listing, as sent in response to LIST, such as
+// "1 120". Anything after the size is ignored.
+func ParseListLine(line string) (msg int, size int64, err error) {
+	fs := strings.Fields(line)
+	if len(fs) < 2 {
+		return 0, 0, ErrInvalidResponse
+	}
+	msg, err = parseMsg(fs[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	size, err = parseNumber(fs[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return msg, size, nil
+}
+
+// ParseUidlLine parses a unique-id listing, as sent in response to UIDL,
+// such as "1 whqtswO00WBw418f9t5JxYwZ". As required by RFC 1939, the
+// unique-id must consist of 1 to 70 printable ASCII characters.
+func ParseUidlLine(line string) (msg int, uid string, err error) {
+	fs := strings.Fields(line)
+	if len(fs) != 2 {
+		return 0, "", ErrInvalidResponse
+	}
+	msg, err = parseMsg(fs[0])
+	if err != nil {
+		return 0, "", err
+	}
+	uid = fs[1]
+	if len(uid) > 70 {
+		return 0, "", ErrInvalidResponse
+	}
+	for i := 0; i < len(uid); i++ {
+		if uid[i] < 0x21 || uid[i] > 0x7e {
+			return 0, "", ErrInvalidResponse
+		}
+	}
+	return msg, uid, nil
+}
+
+// parseUidlLine is ParseUidlLine, ignoring fields after the unique-id if
+// QuirkListFields is set.
+func (c *Client) parseUidlLine(line string) (msg int, uid string, err error) {
+	if c.quirks&QuirkListFields != 0 {
+		if fs := strings.Fields(line); len(fs) > 2 {
+			line = fs[0] + " " + fs[1]
+		}
+	}
+	return ParseUidlLine(line)
+}
+
+// parseNumber parses a non-negative decimal number, without a sign.
+func parseNumber(s string) (int64, error) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, ErrInvalidResponse
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidResponse
+	}
+	return n, nil
+}
+
+// parseMsg parses a message number, which starts at 1.
+func parseMsg(s string) (int, error) {
+	n, err := parseNumber(s)
+	if err != nil || n < 1 || int64(int(n)) != n {
+		return 0, ErrInvalidResponse
+	}
+	return int(n), nil
+}
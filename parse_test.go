@@ -0,0 +1,140 @@
+package pop3
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, l := range []string{"", " ", "2", "x 320", "2 x", "-1 320", "2 -320", "+2 320", "99999999999999999999 1"} {
+		if _, _, err := ParseStat(l); err != ErrInvalidResponse {
+			t.Fatalf("ParseStat(%q) returned %v, expected ErrInvalidResponse", l, err)
+		}
+	}
+	if count, size, err := ParseStat("2 320 trailing"); err != nil || count != 2 || size != 320 {
+		t.Fatalf("Got %d, %d, %v", count, size, err)
+	}
+
+	for _, l := range []string{"", "1", "0 120", "1 x", "1\t"} {
+		if _, _, err := ParseListLine(l); err != ErrInvalidResponse {
+			t.Fatalf("ParseListLine(%q) returned %v, expected ErrInvalidResponse", l, err)
+		}
+	}
+	if msg, size, err := ParseListLine("1 120"); err != nil || msg != 1 || size != 120 {
+		t.Fatalf("Got %d, %d, %v", msg, size, err)
+	}
+
+	for _, l := range []string{"", "1", "0 uid", "x uid", "1 uid extra", "1 " + strings.Repeat("u", 71), "1 \x7fuid"} {
+		if _, _, err := ParseUidlLine(l); err != ErrInvalidResponse {
+			t.Fatalf("ParseUidlLine(%q) returned %v, expected ErrInvalidResponse", l, err)
+		}
+	}
+	if msg, uid, err := ParseUidlLine("1 whqtswO00WBw418f9t5JxYwZ"); err != nil || msg != 1 || uid != "whqtswO00WBw418f9t5JxYwZ" {
+		t.Fatalf("Got %d, %q, %v", msg, uid, err)
+	}
+}
+
+func TestShortResponses(t *testing.T) {
+	c, _ := fakeSession(t, "+OK\n"+
+		"+OK\n"+
+		"+OK\n"+
+		"+OK\n"+
+		"\n"+
+		"+OK 1\n"+
+		"-ERR\n"+
+		"+OK 1\n")
+	if _, _, err := c.Stat(); err != ErrInvalidResponse {
+		t.Fatalf("Stat returned %v, expected ErrInvalidResponse", err)
+	}
+	if _, _, err := c.Stat(); err == nil {
+		t.Fatal("Stat accepted an empty line")
+	}
+	if _, err := c.List(1); err != ErrInvalidResponse {
+		t.Fatalf("List returned %v, expected ErrInvalidResponse", err)
+	}
+	if _, err := c.List(1); err == nil {
+		t.Fatal("List accepted -ERR")
+	}
+	if _, err := c.Uidl(1); err != ErrInvalidResponse {
+		t.Fatalf("Uidl returned %v, expected ErrInvalidResponse", err)
+	}
+}
+
+func FuzzParseStat(f *testing.F) {
+	f.Add("2 320")
+	f.Add("0 0 extra")
+	f.Fuzz(func(t *testing.T, l string) {
+		count, size, err := ParseStat(l)
+		if err != nil {
+			return
+		}
+		if count < 0 || size < 0 {
+			t.Fatalf("ParseStat(%q) returned %d, %d", l, count, size)
+		}
+		count2, size2, err := ParseStat(fmt.Sprintf("%d %d", count, size))
+		if err != nil || count2 != count || size2 != size {
+			t.Fatalf("ParseStat(%q) did not round-trip", l)
+		}
+	})
+}
+
+func FuzzParseListLine(f *testing.F) {
+	f.Add("1 120")
+	f.Add("3 0")
+	f.Fuzz(func(t *testing.T, l string) {
+		msg, size, err := ParseListLine(l)
+		if err != nil {
+			return
+		}
+		if msg < 1 || size < 0 {
+			t.Fatalf("ParseListLine(%q) returned %d, %d", l, msg, size)
+		}
+		msg2, size2, err := ParseListLine(fmt.Sprintf("%d %d", msg, size))
+		if err != nil || msg2 != msg || size2 != size {
+			t.Fatalf("ParseListLine(%q) did not round-trip", l)
+		}
+	})
+}
+
+func FuzzParseUidlLine(f *testing.F) {
+	f.Add("1 whqtswO00WBw418f9t5JxYwZ")
+	f.Add("2 QhdPYR:00WBw1Ph7x7")
+	f.Fuzz(func(t *testing.T, l string) {
+		msg, uid, err := ParseUidlLine(l)
+		if err != nil {
+			return
+		}
+		if msg < 1 || uid == "" || len(uid) > 70 {
+			t.Fatalf("ParseUidlLine(%q) returned %d, %q", l, msg, uid)
+		}
+		msg2, uid2, err := ParseUidlLine(fmt.Sprintf("%d %s", msg, uid))
+		if err != nil || msg2 != msg || uid2 != uid {
+			t.Fatalf("ParseUidlLine(%q) did not round-trip", l)
+		}
+	})
+}
+
+// FuzzClient feeds arbitrary server responses to a session, which must fail
+// with an error rather than panic.
+func FuzzClient(f *testing.F) {
+	f.Add("+OK 2 320\r\n+OK\r\n1 120\r\n.\r\n+OK 1 uid\r\n")
+	f.Add("+OK\r\n\r\n-ERR\r\n+\r\n")
+	f.Fuzz(func(t *testing.T, server string) {
+		for _, q := range []Quirks{0, LenientParsing} {
+			c, err := rawClient("+OK\r\n+OK\r\n+OK\r\n"+server, WithQuirks(q))
+			if err != nil {
+				continue
+			}
+			if c.User("u") != nil || c.Pass("p") != nil {
+				continue
+			}
+			c.Stat()
+			c.List(1)
+			c.ListMessages()
+			c.Uidl(1)
+			c.UidlAll()
+			c.Retr(1)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package pop3
+
+import (
+	"sync"
+	"time"
+)
+
+// A Pool keeps authenticated sessions open between uses, for programs
+// polling many maildrops, such as fetch daemons and webmail backends. Sessions
+// are keyed by server address and credentials.
+//
+// A POP3 session sees a snapshot of the maildrop taken at login, and most
+// servers lock the maildrop for as long as the session lasts: messages that
+// arrive meanwhile are not listed, and other clients of the same maildrop are
+// refused. Since a maildrop can only be locked once, a Pool keeps at most one
+// idle session per key, and closes it after MaxIdleTime to release the lock
+// and let new messages show up in the next session.
+//
+// A Pool may be used by multiple goroutines at once.
+type Pool struct {
+	// Dial opens a new connection to the server at addr.
+	Dial func(addr string) (*Client, error)
+
+	// MaxIdleTime is how long a session may stay idle in the pool before it
+	// is closed.
+	MaxIdleTime time.Duration
+
+	mu     sync.Mutex
+	idle   map[poolKey]*idleSession
+	active map[*Client]poolKey
+	closed bool
+}
+
+type poolKey struct {
+	addr, username, password string
+}
+
+type idleSession struct {
+	c     *Client
+	timer *time.Timer
+}
+
+// NewPool returns a Pool connecting with dial, or with Dial if dial is nil,
+// which closes sessions after one minute of idleness. The idle time may be
+// changed through MaxIdleTime afterwards.
+func NewPool(dial func(addr string) (*Client, error)) *Pool {
+	if dial == nil {
+		dial = func(addr string) (*Client, error) {
+			return Dial(addr)
+		}
+	}
+	return &Pool{
+		Dial:        dial,
+		MaxIdleTime: time.Minute,
+		idle:        make(map[poolKey]*idleSession),
+		active:      make(map[*Client]poolKey),
+	}
+}
+
+// Get returns an authenticated session for the maildrop of username at addr.
+// An idle session is reused if it still answers NOOP; otherwise a new one is
+// dialed and authenticated with Auth. The session must be handed back with
+// Put, or ended with Quit or Close.
+func (p *Pool) Get(addr, username, password string) (*Client, error) {
+	key := poolKey{addr, username, password}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClosed
+	}
+	s := p.idle[key]
+	delete(p.idle, key)
+	p.mu.Unlock()
+
+	if s != nil {
+		s.timer.Stop()
+		if err := s.c.Noop(); err == nil {
+			p.track(s.c, key)
+			return s.c, nil
+		}
+		s.c.Close()
+	}
+
+	c, err := p.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.Auth(username, password); err != nil {
+		c.Close()
+		return nil, err
+	}
+	p.track(c, key)
+	return c, nil
+}
+
+func (p *Pool) track(c *Client, key poolKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active[c] = key
+}
+
+// Put hands a session returned by Get back to the pool. Deletion marks are
+// undone with RSET, since they would otherwise be committed by whichever
+// user of the session happens to quit it; to commit them, call Quit instead
+// of Put. Sessions which have failed or already been ended are discarded, as
+// are sessions whose key already has an idle session.
+func (p *Pool) Put(c *Client) {
+	p.mu.Lock()
+	key, ok := p.active[c]
+	delete(p.active, c)
+	p.mu.Unlock()
+	if !ok || c.State() != StateTransaction {
+		c.Close()
+		return
+	}
+	if err := c.Rset(); err != nil {
+		c.Close()
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed || p.idle[key] != nil {
+		p.mu.Unlock()
+		c.Quit()
+		return
+	}
+	s := &idleSession{c: c}
+	p.idle[key] = s
+	s.timer = time.AfterFunc(p.MaxIdleTime, func() { p.expire(key, s) })
+	p.mu.Unlock()
+}
+
+// expire ends s if it is still idle.
+func (p *Pool) expire(key poolKey, s *idleSession) {
+	p.mu.Lock()
+	if p.idle[key] != s {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.idle, key)
+	p.mu.Unlock()
+	s.c.Quit()
+}
+
+// Close ends all idle sessions and makes further calls to Get fail with
+// ErrClosed. Sessions currently in use are not affected, but are ended
+// rather than kept when handed back with Put.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = make(map[poolKey]*idleSession)
+	p.mu.Unlock()
+	for _, s := range idle {
+		s.timer.Stop()
+		s.c.Quit()
+	}
+	return nil
+}
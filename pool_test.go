@@ -0,0 +1,90 @@
+package pop3
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	var cmdbufs []*bytes.Buffer
+	p := NewPool(func(addr string) (*Client, error) {
+		c, cmdbuf := fakeClient(t, poolServer)
+		cmdbufs = append(cmdbufs, cmdbuf)
+		return c, nil
+	})
+
+	c, err := p.Get("pop.example.com:110", "uname", "password")
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	p.Put(c)
+	c2, err := p.Get("pop.example.com:110", "uname", "password")
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if c2 != c || len(cmdbufs) != 1 {
+		t.Fatal("Get did not reuse the idle session")
+	}
+	other, err := p.Get("pop.example.com:110", "other", "password")
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if other == c || len(cmdbufs) != 2 {
+		t.Fatal("Get reused a session of another maildrop")
+	}
+	p.Put(c2)
+	p.Put(other)
+
+	if err = p.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+	if _, err = p.Get("pop.example.com:110", "uname", "password"); err != ErrClosed {
+		t.Fatalf("Get after Close returned %v, expected ErrClosed", err)
+	}
+	expected := "CAPA\r\nUSER uname\r\nPASS password\r\nRSET\r\nNOOP\r\nRSET\r\nQUIT\r\n"
+	if cmdbufs[0].String() != expected {
+		t.Fatalf("Sent %q, expected %q", cmdbufs[0].String(), expected)
+	}
+}
+
+func TestPoolExpiry(t *testing.T) {
+	var cmdbufs []*bytes.Buffer
+	p := NewPool(func(addr string) (*Client, error) {
+		c, cmdbuf := fakeClient(t, poolServer)
+		cmdbufs = append(cmdbufs, cmdbuf)
+		return c, nil
+	})
+	p.MaxIdleTime = 10 * time.Millisecond
+
+	c, err := p.Get("pop.example.com:110", "uname", "password")
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	p.Put(c)
+	time.Sleep(50 * time.Millisecond)
+	if c.State() != StateClosed {
+		t.Fatal("Idle session was not closed")
+	}
+	if !strings.HasSuffix(cmdbufs[0].String(), "RSET\r\nQUIT\r\n") {
+		t.Fatalf("Sent %q", cmdbufs[0].String())
+	}
+	if _, err = p.Get("pop.example.com:110", "uname", "password"); err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if len(cmdbufs) != 2 {
+		t.Fatal("Get did not dial a new session")
+	}
+}
+
+var poolServer = `+OK good morning
++OK
+.
++OK send PASS
++OK welcome
++OK
++OK
++OK
++OK bye
+`
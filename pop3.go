@@ -7,66 +7,225 @@ package pop3
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/tls"
 	"encoding/base64"
-	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/smtp"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// The POP3 client.
+// The POP3 client. A Client may be used by multiple goroutines at once; each
+// command and its response are exchanged without interruption.
 type Client struct {
-	conn net.Conn
-	bin  *bufio.Reader
+	mu     sync.Mutex
+	conn   net.Conn
+	bin    *bufio.Reader
+	trace  io.Writer
+	utf8   bool
+	lang   string
+	state  State
+	quirks Quirks
+
+	greeting string
+
+	statsMu sync.Mutex
+	stats   Stats
+	hook    func(cmd string, rtt time.Duration, err error)
+
+	maxLine int
+	maxSize int64
+
+	readLimit *bucket
+	cmdLimit  *bucket
+
+	// sasl is set while an AUTH exchange awaits a client response, so that
+	// the response is kept out of the trace.
+	sasl bool
+}
+
+// An Option configures optional behaviour of a Client.
+type Option func(*Client)
+
+// WithMaxLineLength limits the length of lines accepted from the server to n
+// octets, excluding the line ending. Longer lines fail with ErrLineTooLong.
+func WithMaxLineLength(n int) Option {
+	return func(c *Client) {
+		c.maxLine = n
+	}
+}
+
+// WithMaxMessageSize limits the size of multi-line responses, such as
+// messages downloaded with Retr, to n octets as sent on the wire. Larger
+// responses fail with ErrMessageTooLarge.
+func WithMaxMessageSize(n int64) Option {
+	return func(c *Client) {
+		c.maxSize = n
+	}
 }
 
 // Dial creates an unsecured connection to the POP3 server at the given address
 // and returns the corresponding Client.
-func Dial(addr string) (*Client, error) {
+func Dial(addr string, opts ...Option) (*Client, error) {
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
-	return NewClient(conn)
+	return NewClient(conn, opts...)
 }
 
 // DialTLS creates a TLS-secured connection to the POP3 server at the given
-// address and returns the corresponding Client.
-func DialTLS(addr string, config *tls.Config) (*Client, error) {
+// address and returns the corresponding Client. Use PinSPKI to restrict the
+// certificates config accepts.
+func DialTLS(addr string, config *tls.Config, opts ...Option) (*Client, error) {
 	conn, err := tls.Dial("tcp", addr, config)
 	if err != nil {
 		return nil, err
 	}
-	return NewClient(conn)
+	return NewClient(conn, opts...)
 }
 
 // NewClient returns a new Client object using an existing connection.
-func NewClient(conn net.Conn) (*Client, error) {
-	client := &Client{
-		bin:  bufio.NewReader(conn),
-		conn: conn,
+func NewClient(conn net.Conn, opts ...Option) (*Client, error) {
+	client := &Client{quirks: DefaultQuirks}
+	for _, opt := range opts {
+		opt(client)
 	}
-	// send dud command, to read a line
-	_, err := client.Cmd("")
+	client.setConn(conn)
+	// send no command, to read the greeting
+	l, err := client.exchange("")
 	if err != nil {
 		return nil, err
 	}
+	if !strings.HasPrefix(l, "+") && (client.quirks&QuirkGreeting == 0 || strings.HasPrefix(l, "-")) {
+		return nil, client.newError(strings.TrimPrefix(l, "-ERR "))
+	}
+	client.greeting = strings.TrimPrefix(strings.TrimPrefix(l, "+OK"), " ")
 	return client, nil
 }
 
+// setConn makes conn the connection used for all further exchanges.
+func (c *Client) setConn(conn net.Conn) {
+	c.conn = conn
+	var r io.Reader = countingReader{conn, c}
+	if c.readLimit != nil {
+		r = limitedReader{r, c.readLimit}
+	}
+	c.bin = bufio.NewReader(r)
+}
+
+// send writes a command to the server, tracing it with any credentials
+// masked.
+func (c *Client) send(cmd string) error {
+	if cmd == "" {
+		return nil
+	}
+	if c.trace != nil {
+		line := redact(cmd)
+		if c.sasl {
+			line = "********\r\n"
+		}
+		io.WriteString(c.trace, "C: "+line)
+	}
+	n, err := io.WriteString(c.conn, cmd)
+	c.statsMu.Lock()
+	c.stats.BytesWritten += int64(n)
+	c.statsMu.Unlock()
+	if err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+// readLine reads a single line sent by the server, without its line ending.
+// Lines may be of any length up to the limit set with WithMaxLineLength; a
+// longer line closes the connection, since the rest of the response can no
+// longer be told apart from the next one.
+func (c *Client) readLine() (string, error) {
+	var line []byte
+	for {
+		frag, err := c.bin.ReadSlice('\n')
+		line = append(line, frag...)
+		if c.maxLine > 0 && len(bytes.TrimRight(line, "\r\n")) > c.maxLine {
+			c.closeConn()
+			return "", ErrLineTooLong
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return "", c.fail(err)
+		}
+		break
+	}
+	bareLF := bytes.HasSuffix(line, []byte("\n")) && !bytes.HasSuffix(line, []byte("\r\n"))
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if c.trace != nil {
+		io.WriteString(c.trace, "S: "+string(line)+"\r\n")
+	}
+	if bareLF && c.quirks&QuirkBareLF == 0 {
+		// The rest of the response cannot be trusted to be framed either.
+		c.closeConn()
+		return "", ErrInvalidResponse
+	}
+	return string(line), nil
+}
+
+// exchange sends cmd, which must include its line ending, and reads the
+// first line of the response, keeping track of the session state.
+func (c *Client) exchange(cmd string) (string, error) {
+	var verb string
+	if !c.sasl && cmd != "" {
+		verb = strings.ToUpper(strings.TrimRight(strings.SplitN(cmd, " ", 2)[0], "\r\n"))
+	}
+	if err := c.checkState(verb); err != nil {
+		return "", err
+	}
+	if c.cmdLimit != nil && cmd != "" {
+		c.cmdLimit.wait(1)
+	}
+	start := time.Now()
+	err := c.send(cmd)
+	var l string
+	if err == nil {
+		l, err = c.readLine()
+	}
+	c.record(verb, time.Since(start), l, err)
+	if err != nil {
+		return "", err
+	}
+	wasSasl := c.sasl
+	c.sasl = (wasSasl || verb == "AUTH") && (l == "+" || strings.HasPrefix(l, "+ "))
+	if strings.HasPrefix(l, "+OK") {
+		switch {
+		case verb == "PASS" || verb == "APOP" || verb == "AUTH" || wasSasl:
+			c.state = StateTransaction
+		case verb == "QUIT" && c.state == StateTransaction:
+			c.state = StateUpdate
+		}
+	}
+	return l, nil
+}
+
 // CmdAux used to send user and pass
 func (c *Client) CmdAux(format string, args ...interface{}) (string, error) {
-	fmt.Fprintf(c.conn, format, args...)
-	line, _, err := c.bin.ReadLine()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cmdAux(format, args...)
+}
+
+func (c *Client) cmdAux(format string, args ...interface{}) (string, error) {
+	l, err := c.exchange(fmt.Sprintf(format, args...))
 	if err != nil {
 		return "", err
 	}
-	l := string(line)
-	if l[0:3] != "+OK" {
-		err = errors.New(l[5:])
+	if !strings.HasPrefix(l, "+OK") {
+		err = c.newError(strings.TrimPrefix(strings.TrimPrefix(l, "-ERR"), " "))
 	}
 	if len(l) >= 4 {
 		return l[4:], err
@@ -77,51 +236,245 @@ func (c *Client) CmdAux(format string, args ...interface{}) (string, error) {
 // Convenience function to synchronously run an arbitrary command and wait for
 // output. The terminating CRLF must be included in the format string.
 //
-// Output sent after the first line must be retrieved via readLines.
+// Output sent after the first line must be retrieved via ReadLines. Other
+// goroutines may issue commands in between; callers sharing the Client must
+// not use Cmd for commands with multi-line responses.
 func (c *Client) Cmd(format string, args ...interface{}) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cmd(format, args...)
+}
+
+func (c *Client) cmd(format string, args ...interface{}) (string, error) {
 	if format != "" {
 		format += "\r\n"
 	}
-	fmt.Fprintf(c.conn, format, args...)
-	line, _, err := c.bin.ReadLine()
+	l, err := c.exchange(fmt.Sprintf(format, args...))
 	if err != nil {
 		return "", err
 	}
-	l := string(line)
 	last := l
 	if split := strings.SplitN(l, " ", 2); len(split) == 2 {
 		last = split[1]
 	}
-	if l[0] != '+' {
-		return "", errors.New(last)
+	if !strings.HasPrefix(l, "+") {
+		return "", c.newError(last)
 	}
 	return last, nil
 }
 
 func (c *Client) ReadLines() (lines []string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readLines(nil)
+}
+
+// readLines is ReadLines, calling progress with the number of octets
+// received for every chunk read, if progress is not nil.
+func (c *Client) readLines(progress func(n int)) (lines []string, err error) {
+	block, err := c.readBlock(progress)
 	lines = make([]string, 0)
-	l, _, err := c.bin.ReadLine()
-	line := string(l)
-	for err == nil && line != "." {
-		if len(line) > 0 && line[0] == '.' {
-			line = line[1:]
+	if len(block) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(block), "\n"), "\n")
+	}
+	return lines, err
+}
+
+// readBlock reads the rest of a multi-line response, with lines separated
+// by LF. A response exceeding the limits set with WithMaxLineLength or
+// WithMaxMessageSize closes the connection.
+func (c *Client) readBlock(progress func(n int)) ([]byte, error) {
+	if c.state == StateClosed {
+		return nil, ErrClosed
+	}
+	d := &DotReader{r: c.bin, crlf: c.quirks&QuirkBareLF == 0}
+	r := &blockReader{c: c, d: d, progress: progress}
+	block, err := io.ReadAll(r)
+	if err == nil && c.trace != nil {
+		io.WriteString(c.trace, "S: .\r\n")
+	}
+	return block, err
+}
+
+// blockReader reads a multi-line response for a Client, enforcing its limits
+// and tracing the lines received.
+type blockReader struct {
+	c        *Client
+	d        *DotReader
+	progress func(n int)
+	line     []byte
+	lineLen  int
+	size     int64
+}
+
+func (r *blockReader) Read(p []byte) (int, error) {
+	n, err := r.d.Read(p)
+	wire := n
+	for _, b := range p[:n] {
+		if b != '\n' {
+			r.lineLen++
+			if r.c.trace != nil {
+				r.line = append(r.line, b)
+			}
+			continue
+		}
+		// The server sent CRLF where LF is returned.
+		wire++
+		r.lineLen = 0
+		if r.c.trace != nil {
+			io.WriteString(r.c.trace, "S: "+string(r.line)+"\r\n")
+			r.line = r.line[:0]
 		}
-		lines = append(lines, line)
-		l, _, err = c.bin.ReadLine()
-		line = string(l)
 	}
-	return
+	r.size += int64(wire)
+	if r.c.maxLine > 0 && r.lineLen > r.c.maxLine {
+		r.c.closeConn()
+		return n, ErrLineTooLong
+	}
+	if r.c.maxSize > 0 && r.size > r.c.maxSize {
+		r.c.closeConn()
+		return n, ErrMessageTooLarge
+	}
+	if r.progress != nil && wire > 0 {
+		r.progress(wire)
+	}
+	switch {
+	case err == io.ErrUnexpectedEOF && r.c.quirks&QuirkMissingDot != 0:
+		r.c.closeConn()
+		err = io.EOF
+	case err == ErrInvalidResponse:
+		r.c.closeConn()
+	case err != nil && err != io.EOF:
+		err = r.c.fail(err)
+	}
+	return n, err
 }
 
 func (c *Client) Caps() (caps []string, err error) {
-	_, err = c.Cmd("CAPA")
-	return c.ReadLines()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps()
+}
+
+func (c *Client) caps() (caps []string, err error) {
+	_, err = c.cmd("CAPA")
+	if _, ok := err.(*Error); ok && c.quirks&QuirkNoCapa != 0 {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return c.readLines(nil)
+}
+
+// capArgs looks up the capability name in caps, as returned by Caps, and
+// returns its arguments.
+func capArgs(caps []string, name string) (args []string, ok bool) {
+	for _, cp := range caps {
+		fs := strings.Fields(cp)
+		if len(fs) > 0 && strings.EqualFold(fs[0], name) {
+			return fs[1:], true
+		}
+	}
+	return nil, false
+}
+
+// Utf8 sends the UTF8 command defined in RFC 6856, after which usernames and
+// passwords may contain UTF-8 and the server sends internationalized
+// messages and responses unmodified. It is only valid before
+// authentication; Auth issues it itself if the credentials are not ASCII
+// and the server advertises the UTF8 capability.
+//
+// Servers advertising "UTF8 USER" accept UTF-8 in USER without it.
+func (c *Client) Utf8() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enableUtf8()
+}
+
+func (c *Client) enableUtf8() error {
+	_, err := c.cmd("UTF8")
+	if err != nil {
+		return err
+	}
+	c.utf8 = true
+	return nil
+}
+
+// A Language is a language supported by the server for its responses.
+type Language struct {
+	// Tag is the language tag, as defined in RFC 5646.
+	Tag string
+	// Description is a description of the language, which may be in the
+	// language itself. Octets which are not UTF-8 are replaced by U+FFFD.
+	Description string
+}
+
+// ListLanguages returns the languages the server supports for its
+// responses, using the LANG command defined in RFC 6856.
+func (c *Client) ListLanguages() (langs []Language, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.cmd("LANG")
+	if err != nil {
+		return nil, err
+	}
+	lines, err := c.readLines(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		fs := strings.SplitN(l, " ", 2)
+		lang := Language{Tag: fs[0]}
+		if len(fs) == 2 {
+			lang.Description = strings.ToValidUTF8(fs[1], "\uFFFD")
+		}
+		langs = append(langs, lang)
+	}
+	return langs, nil
+}
+
+// SetLanguage asks the server to send its human-readable responses, such as
+// the Text of an Error, in the language identified by tag. The special tag
+// "*" selects a language according to the server's preference.
+//
+// Servers may keep responses to ASCII until UTF8 is in effect, so Utf8
+// should be issued first to select languages not written in ASCII.
+func (c *Client) SetLanguage(tag string) error {
+	if err := checkArgs(tag); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, err := c.cmd("LANG %s", tag)
+	if err != nil {
+		return err
+	}
+	// The server echoes the tag of the language now in effect.
+	if fs := strings.Fields(l); len(fs) > 0 {
+		tag = fs[0]
+	}
+	c.lang = tag
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
 }
 
 // Auth sends the given username and password to the server, calling the User
 // and Pass methods as appropriate.
 func (c *Client) Auth(username, password string) error {
-	caps, err := c.Caps()
+	if err := checkArgs(username, password); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	caps, err := c.caps()
 	var sasl []string
 	plain := false
 	for _, c := range caps {
@@ -132,10 +485,15 @@ func (c *Client) Auth(username, password string) error {
 			plain = true
 		}
 	}
+	if _, ok := capArgs(caps, "UTF8"); ok && !c.utf8 && !(isASCII(username) && isASCII(password)) {
+		if err = c.enableUtf8(); err != nil {
+			return err
+		}
+	}
 	if sasl != nil {
 		for _, v := range sasl {
 			if v == "CRAM-MD5" {
-				line, err := c.Cmd("AUTH CRAM-MD5")
+				line, err := c.cmd("AUTH CRAM-MD5")
 				if err != nil {
 					return err
 				}
@@ -149,16 +507,80 @@ func (c *Client) Auth(username, password string) error {
 					return err
 				}
 				response := base64.StdEncoding.EncodeToString(auth)
-				_, err = c.Cmd(response)
+				_, err = c.cmd(response)
 				return err
 			}
 		}
 	}
 	if plain {
-		_, err = c.Cmd("AUTH %s %s", username, base64.StdEncoding.EncodeToString([]byte(password)))
+		_, err = c.cmd("AUTH %s %s", username, base64.StdEncoding.EncodeToString([]byte(password)))
+		return err
+	}
+	if err = c.user(username); err != nil {
+		return err
+	}
+	return c.pass(password)
+}
+
+// XOAuth2 authenticates with an OAuth 2.0 access token using the XOAUTH2
+// SASL mechanism, as offered by Gmail and Outlook.com.
+func (c *Client) XOAuth2(username, token string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ir := base64.StdEncoding.EncodeToString([]byte("user=" + username + "\x01auth=Bearer " + token + "\x01\x01"))
+	_, err := c.cmd("AUTH XOAUTH2 %s", ir)
+	if err != nil || !c.sasl {
+		return err
+	}
+	// The server rejected the token with a challenge describing the error,
+	// to which an empty response ends the exchange.
+	l, err := c.exchange("\r\n")
+	if err != nil || strings.HasPrefix(l, "+OK") {
+		return err
+	}
+	return c.newError(strings.TrimPrefix(strings.TrimPrefix(l, "-ERR"), " "))
+}
+
+// User sends the USER command with the given username.
+func (c *Client) User(username string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.user(username)
+}
+
+func (c *Client) user(username string) error {
+	if err := checkArgs(username); err != nil {
+		return err
+	}
+	_, err := c.cmdAux("USER %s\r\n", username)
+	return err
+}
+
+// Pass sends the PASS command with the given password. It must follow a
+// successful call to User.
+func (c *Client) Pass(password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pass(password)
+}
+
+func (c *Client) pass(password string) error {
+	if err := checkArgs(password); err != nil {
 		return err
 	}
-	return errors.New("No supported auth methods found.")
+	_, err := c.cmdAux("PASS %s\r\n", password)
+	return err
+}
+
+// checkArgs returns ErrInvalidArgument if any of the command arguments
+// contains a line ending.
+func checkArgs(args ...string) error {
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "\r\n") {
+			return ErrInvalidArgument
+		}
+	}
+	return nil
 }
 
 // Stat retrieves a drop listing for the current maildrop, consisting of the
@@ -167,103 +589,286 @@ func (c *Client) Auth(username, password string) error {
 // maildrop is ignored. In the event of an error, all returned numeric values
 // will be 0.
 func (c *Client) Stat() (count, size int, err error) {
-	l, err := c.Cmd("STAT")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, err := c.cmd("STAT")
 	if err != nil {
 		return 0, 0, err
 	}
-	parts := strings.Fields(l)
-	count, err = strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, 0, errors.New("Invalid server response")
-	}
-	size, err = strconv.Atoi(parts[1])
+	count, n, err := ParseStat(l)
 	if err != nil {
-		return 0, 0, errors.New("Invalid server response")
+		return 0, 0, err
 	}
-	return
+	return count, int(n), nil
 }
 
 // List returns the size of the given message, if it exists. If the message
 // does not exist, or another error is encountered, the returned size will be
 // 0.
 func (c *Client) List(msg int) (size int, err error) {
-	l, err := c.Cmd("LIST %d", msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list(msg)
+}
+
+func (c *Client) list(msg int) (size int, err error) {
+	l, err := c.cmd("LIST %d", msg)
 	if err != nil {
 		return 0, err
 	}
-	size, err = strconv.Atoi(strings.Fields(l)[1])
+	_, n, err := ParseListLine(l)
+	if err != nil && c.quirks&QuirkListFields != 0 && len(strings.Fields(l)) == 1 {
+		n, err = parseNumber(l)
+	}
 	if err != nil {
-		return 0, errors.New("Invalid server response")
+		return 0, err
 	}
-	return size, nil
+	return int(n), nil
 }
 
 // ListAll returns a list of all messages and their sizes.
+//
+// Deprecated: Use ListMessages, which also returns unique-ids.
 func (c *Client) ListAll() (msgs []int, sizes []int, err error) {
-	_, err = c.Cmd("LIST")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list, err := c.listAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs = make([]int, len(list))
+	sizes = make([]int, len(list))
+	for i, m := range list {
+		msgs[i] = m.Seq
+		sizes[i] = int(m.Size)
+	}
+	return msgs, sizes, nil
+}
+
+// listAll issues LIST, returning the messages without unique-ids.
+func (c *Client) listAll() (msgs []MessageInfo, err error) {
+	_, err = c.cmd("LIST")
+	if err != nil {
+		return nil, err
+	}
+	lines, err := c.readLines(nil)
+	if err != nil {
+		return nil, err
+	}
+	msgs = make([]MessageInfo, 0, len(lines))
+	for _, l := range lines {
+		var m MessageInfo
+		m.Seq, m.Size, err = ParseListLine(l)
+		if err != nil {
+			if c.quirks&QuirkListFields != 0 {
+				continue
+			}
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// Uidl returns the unique-id of the given message, if it exists.
+func (c *Client) Uidl(msg int) (uid string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, err := c.cmd("UIDL %d", msg)
+	if err != nil {
+		return "", err
+	}
+	_, uid, err = c.parseUidlLine(l)
+	return uid, err
+}
+
+// UidlAll returns a list of all messages and their unique-ids.
+func (c *Client) UidlAll() (msgs []int, uids []string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.cmd("UIDL")
 	if err != nil {
 		return
 	}
-	lines, err := c.ReadLines()
+	lines, err := c.readLines(nil)
 	if err != nil {
 		return
 	}
-	msgs = make([]int, len(lines), len(lines))
-	sizes = make([]int, len(lines), len(lines))
-	for i, l := range lines {
-		var m, s int
-		fs := strings.Fields(l)
-		m, err = strconv.Atoi(fs[0])
+	msgs = make([]int, 0, len(lines))
+	uids = make([]string, 0, len(lines))
+	for _, l := range lines {
+		m, uid, err := c.parseUidlLine(l)
 		if err != nil {
-			return
+			if c.quirks&QuirkListFields != 0 {
+				continue
+			}
+			return nil, nil, err
 		}
-		s, err = strconv.Atoi(fs[1])
+		msgs = append(msgs, m)
+		uids = append(uids, uid)
+	}
+	return msgs, uids, nil
+}
+
+// MessageInfo describes a message in the maildrop.
+type MessageInfo struct {
+	// Seq is the message number, valid for the current session.
+	Seq int
+	// Size is the size of the message in octets.
+	Size int64
+	// UID is the unique-id of the message, which persists across sessions.
+	// It is empty if the server does not support UIDL.
+	UID string
+}
+
+// ListMessages returns all messages in the maildrop, issuing LIST for their
+// sizes and UIDL for their unique-ids.
+func (c *Client) ListMessages() (msgs []MessageInfo, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msgs, err = c.listAll()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int]*MessageInfo, len(msgs))
+	for i := range msgs {
+		index[msgs[i].Seq] = &msgs[i]
+	}
+
+	_, err = c.cmd("UIDL")
+	if _, ok := err.(*Error); ok {
+		return msgs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	lines, err := c.readLines(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		seq, uid, err := c.parseUidlLine(l)
 		if err != nil {
-			return
+			if c.quirks&QuirkListFields != 0 {
+				continue
+			}
+			return nil, err
+		}
+		if m := index[seq]; m != nil {
+			m.UID = uid
 		}
-		msgs[i] = m
-		sizes[i] = s
 	}
-	return
+	return msgs, nil
 }
 
 // Retr downloads and returns the given message. The lines are separated by LF,
 // whatever the server sent.
 func (c *Client) Retr(msg int) (text string, err error) {
-	_, err = c.Cmd("RETR %d", msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.cmd("RETR %d", msg)
+	if err != nil {
+		return "", err
+	}
+	block, err := c.readBlock(nil)
+	text = strings.TrimSuffix(string(block), "\n")
+	return
+}
+
+// Top downloads and returns the headers of the given message and the first n
+// lines of its body. The lines are separated by LF, as for Retr.
+func (c *Client) Top(msg, n int) (text string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.cmd("TOP %d %d", msg, n)
+	if err != nil {
+		return "", err
+	}
+	block, err := c.readBlock(nil)
+	text = strings.TrimSuffix(string(block), "\n")
+	return
+}
+
+// RetrWithProgress is like Retr, but calls fn as the message is received
+// with the number of octets read so far and the size of the message as
+// reported by LIST. The octet count includes line endings as sent by the
+// server, so it matches total for servers reporting exact sizes. If fn is
+// nil, no progress is reported.
+func (c *Client) RetrWithProgress(msg int, fn func(read, total int64)) (text string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	size, err := c.list(msg)
 	if err != nil {
 		return "", err
 	}
-	lines, err := c.ReadLines()
-	text = strings.Join(lines, "\n")
+	_, err = c.cmd("RETR %d", msg)
+	if err != nil {
+		return "", err
+	}
+	var progress func(n int)
+	if fn != nil {
+		var read int64
+		total := int64(size)
+		fn(0, total)
+		progress = func(n int) {
+			read += int64(n)
+			fn(read, total)
+		}
+	}
+	block, err := c.readBlock(progress)
+	text = strings.TrimSuffix(string(block), "\n")
 	return
 }
 
 // Dele marks the given message as deleted.
 func (c *Client) Dele(msg int) (err error) {
-	_, err = c.Cmd("DELE %d", msg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.cmd("DELE %d", msg)
 	return
 }
 
 // Noop does nothing, but will prolong the end of the connection if the server
 // has a timeout set.
 func (c *Client) Noop() (err error) {
-	_, err = c.Cmd("NOOP")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.cmd("NOOP")
 	return
 }
 
 // Rset unmarks any messages marked for deletion previously in this session.
 func (c *Client) Rset() (err error) {
-	_, err = c.Cmd("RSET")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.cmd("RSET")
 	return
 }
 
-// Quit sends the QUIT message to the POP3 server and closes the connection.
+// Quit sends the QUIT message to the POP3 server, committing deletions, and
+// closes the connection. The connection is closed even if the server
+// rejects the command or it cannot be sent.
 func (c *Client) Quit() error {
-	_, err := c.Cmd("QUIT")
-	if err != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.cmd("QUIT")
+	if err == ErrClosed {
 		return err
 	}
-	c.conn.Close()
-	return nil
+	if c.state != StateClosed {
+		c.closeConn()
+	}
+	return err
+}
+
+// Close closes the connection without sending QUIT, so that messages marked
+// as deleted are kept on the server. Any command issued afterwards fails
+// with ErrClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == StateClosed {
+		return ErrClosed
+	}
+	c.state = StateClosed
+	return c.conn.Close()
 }
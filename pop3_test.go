@@ -42,6 +42,35 @@ func (f faker) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+// fakeClient returns a Client talking to a server replaying the given
+// script, and the buffer receiving the commands sent.
+func fakeClient(t *testing.T, server string, opts ...Option) (*Client, *bytes.Buffer) {
+	server = strings.Join(strings.Split(server, "\n"), "\r\n")
+	var cmdbuf bytes.Buffer
+	var fake faker
+	fake.ReadWriter = struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(server), &cmdbuf}
+	c, err := NewClient(fake, opts...)
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	return c, &cmdbuf
+}
+
+// fakeSession is like fakeClient, but logs in with USER and PASS first.
+func fakeSession(t *testing.T, server string, opts ...Option) (*Client, *bytes.Buffer) {
+	c, cmdbuf := fakeClient(t, server, opts...)
+	if err := c.User("uname"); err != nil {
+		t.Fatalf("User failed: %s", err)
+	}
+	if err := c.Pass("password"); err != nil {
+		t.Fatalf("Pass failed: %s", err)
+	}
+	return c, cmdbuf
+}
+
 func TestBasic (t *testing.T) {
 	basicServer := strings.Join(strings.Split(basicServer, "\n"), "\r\n")
 	basicClient := strings.Join(strings.Split(basicClient, "\n"), "\r\n")
@@ -57,7 +86,7 @@ func TestBasic (t *testing.T) {
 	}
 
 	if err = c.User("uname"); err != nil {
-		t.Fatal("User failed: %s", err)
+		t.Fatalf("User failed: %s", err)
 	}
 
 	if err = c.Pass("password1"); err == nil {
@@ -65,11 +94,11 @@ func TestBasic (t *testing.T) {
 	}
 
 	if err = c.Auth("uname", "password2"); err != nil {
-		t.Fatal("Auth failed: %s", err)
+		t.Fatalf("Auth failed: %s", err)
 	}
 
 	if err = c.Noop(); err != nil {
-		t.Fatal("Noop failed: %s", err)
+		t.Fatalf("Noop failed: %s", err)
 	}
 
 	bcmdbuf.Flush()
@@ -81,6 +110,7 @@ func TestBasic (t *testing.T) {
 var basicServer = `+OK good morning
 +OK send PASS
 -ERR [AUTH] mismatched username and password
+-ERR unknown command
 +OK send PASS
 +OK welcome
 +OK
@@ -88,7 +118,302 @@ var basicServer = `+OK good morning
 
 var basicClient = `USER uname
 PASS password1
+CAPA
 USER uname
 PASS password2
 NOOP
 `
+
+func TestConcurrent(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		server.Write([]byte("+OK good morning\r\n"))
+		r := bufio.NewReader(server)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch line {
+			case "USER uname\r\n", "PASS password\r\n", "NOOP\r\n":
+				server.Write([]byte("+OK\r\n"))
+			case "UIDL\r\n":
+				server.Write([]byte("+OK\r\n1 a\r\n2 b\r\n.\r\n"))
+			default:
+				server.Write([]byte("-ERR unexpected\r\n"))
+			}
+		}
+	}()
+	c, err := NewClient(client)
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	defer client.Close()
+	if err = c.User("uname"); err != nil {
+		t.Fatalf("User failed: %s", err)
+	}
+	if err = c.Pass("password"); err != nil {
+		t.Fatalf("Pass failed: %s", err)
+	}
+
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			var err error
+			for j := 0; j < 50 && err == nil; j++ {
+				if err = c.Noop(); err == nil {
+					_, _, err = c.UidlAll()
+				}
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("Concurrent commands failed: %s", err)
+		}
+	}
+}
+
+func TestListMessages(t *testing.T) {
+	c, _ := fakeSession(t, listServer)
+	msgs, err := c.ListMessages()
+	if err != nil {
+		t.Fatalf("ListMessages failed: %s", err)
+	}
+	expected := []MessageInfo{{1, 120, "whqtswO00WBw418f9t5JxYwZ"}, {3, 4096, "QhdPYR:00WBw1Ph7x7"}}
+	if len(msgs) != len(expected) {
+		t.Fatalf("Got %v, expected %v", msgs, expected)
+	}
+	for i := range msgs {
+		if msgs[i] != expected[i] {
+			t.Fatalf("Got %v, expected %v", msgs, expected)
+		}
+	}
+}
+
+var listServer = `+OK good morning
++OK send PASS
++OK welcome
++OK 2 messages
+1 120
+3 4096
+.
++OK
+3 QhdPYR:00WBw1Ph7x7
+1 whqtswO00WBw418f9t5JxYwZ
+.
+`
+
+func TestLongLines(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	server := "+OK good morning\n+OK send PASS\n+OK welcome\n+OK\n" + long + "\n.\n"
+
+	c, _ := fakeSession(t, server)
+	text, err := c.Retr(1)
+	if err != nil {
+		t.Fatalf("Retr failed: %s", err)
+	}
+	if text != long {
+		t.Fatalf("Got line of %d octets, expected %d", len(text), len(long))
+	}
+
+	c, _ = fakeSession(t, server, WithMaxLineLength(1000))
+	if _, err = c.Retr(1); err != ErrLineTooLong {
+		t.Fatalf("Got %v, expected ErrLineTooLong", err)
+	}
+
+	c, _ = fakeSession(t, server, WithMaxMessageSize(5000))
+	if _, err = c.Retr(1); err != ErrMessageTooLarge {
+		t.Fatalf("Got %v, expected ErrMessageTooLarge", err)
+	}
+}
+
+func TestRetrWithProgress(t *testing.T) {
+	c, _ := fakeSession(t, "+OK good morning\n+OK send PASS\n+OK welcome\n"+
+		"+OK 1 26\n+OK message follows\nSubject: hi\n\n..dot\nend\n.\n")
+	var calls [][2]int64
+	text, err := c.RetrWithProgress(1, func(read, total int64) {
+		calls = append(calls, [2]int64{read, total})
+	})
+	if err != nil {
+		t.Fatalf("RetrWithProgress failed: %s", err)
+	}
+	if text != "Subject: hi\n\n.dot\nend" {
+		t.Fatalf("Got %q", text)
+	}
+	if len(calls) < 2 || calls[0] != [2]int64{0, 26} {
+		t.Fatalf("Got calls %v, expected (0, 26) first", calls)
+	}
+	for i, call := range calls {
+		if call[1] != 26 || i > 0 && call[0] < calls[i-1][0] {
+			t.Fatalf("Got calls %v", calls)
+		}
+	}
+	// The dot-stuffing is not counted, the CRLF line endings are.
+	if read := calls[len(calls)-1][0]; read != 26 {
+		t.Fatalf("Read %d octets, expected 26", read)
+	}
+
+	c, _ = fakeSession(t, "+OK good morning\n+OK send PASS\n+OK welcome\n"+
+		"+OK 1 4\n+OK message follows\nend\n.\n")
+	if text, err := c.RetrWithProgress(1, nil); err != nil || text != "end" {
+		t.Fatalf("RetrWithProgress without fn returned %q, %v", text, err)
+	}
+}
+
+func TestState(t *testing.T) {
+	c, cmdbuf := fakeClient(t, stateServer)
+
+	if _, _, err := c.Stat(); err == nil {
+		t.Fatal("Stat succeeded before authentication")
+	} else if _, ok := err.(*StateError); !ok {
+		t.Fatalf("Stat returned %#v, expected *StateError", err)
+	}
+	if cmdbuf.Len() != 0 {
+		t.Fatalf("Sent %q in the wrong state", cmdbuf.String())
+	}
+	if err := c.User("uname"); err != nil {
+		t.Fatalf("User failed: %s", err)
+	}
+	if c.Pass("password1") == nil || c.State() != StateAuthorization {
+		t.Fatal("Pass succeeded inappropriately")
+	}
+	c.User("uname")
+	if err := c.Pass("password2"); err != nil || c.State() != StateTransaction {
+		t.Fatalf("Pass failed: %v", err)
+	}
+
+	err := c.Noop()
+	if _, ok := err.(*ConnError); !ok {
+		t.Fatalf("Noop returned %#v, expected *ConnError", err)
+	}
+	if c.State() != StateClosed {
+		t.Fatalf("Got state %s after connection loss", c.State())
+	}
+	if err = c.Noop(); err != ErrClosed {
+		t.Fatalf("Noop returned %v after connection loss, expected ErrClosed", err)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	c, _ := fakeSession(t, quitServer)
+	if err := c.Quit(); err == nil {
+		t.Fatal("Quit succeeded inappropriately")
+	}
+	if c.State() != StateClosed {
+		t.Fatalf("Got state %s after failed Quit", c.State())
+	}
+	if err := c.Quit(); err != ErrClosed {
+		t.Fatalf("Second Quit returned %v, expected ErrClosed", err)
+	}
+	if _, err := c.ReadLines(); err != ErrClosed {
+		t.Fatalf("ReadLines returned %v, expected ErrClosed", err)
+	}
+	if err := c.Close(); err != ErrClosed {
+		t.Fatalf("Close returned %v, expected ErrClosed", err)
+	}
+}
+
+var quitServer = `+OK good morning
++OK send PASS
++OK welcome
+-ERR [SYS/PERM] some deleted messages not removed
+`
+
+var stateServer = `+OK good morning
++OK send PASS
+-ERR [AUTH] mismatched username and password
++OK send PASS
++OK welcome`
+
+var utf8Tests = []struct {
+	caps, username, sent string
+}{
+	{"UTF8", "us\u00e9r", "CAPA\r\nUTF8\r\nUSER us\u00e9r\r\nPASS password\r\n"},
+	{"UTF8", "uname", "CAPA\r\nUSER uname\r\nPASS password\r\n"},
+	{"TOP", "us\u00e9r", "CAPA\r\nUSER us\u00e9r\r\nPASS password\r\n"},
+}
+
+func TestUtf8(t *testing.T) {
+	for _, tt := range utf8Tests {
+		c, cmdbuf := fakeClient(t, "+OK good morning\n+OK\n"+tt.caps+"\n.\n+OK\n+OK\n+OK\n")
+		if err := c.Auth(tt.username, "password"); err != nil {
+			t.Fatalf("Auth failed: %s", err)
+		}
+		if cmdbuf.String() != tt.sent {
+			t.Fatalf("With %s and %q, sent %q, expected %q", tt.caps, tt.username, cmdbuf.String(), tt.sent)
+		}
+	}
+
+	c, _ := fakeClient(t, "+OK good morning\n+OK UTF8 enabled\n-ERR Nachricht \xfc\n")
+	if err := c.Utf8(); err != nil {
+		t.Fatalf("Utf8 failed: %s", err)
+	}
+	err := c.User("us\u00e9r")
+	if e, ok := err.(*Error); !ok || e.Text != "Nachricht \ufffd" {
+		t.Fatalf("User returned %#v, expected text with U+FFFD", err)
+	}
+}
+
+func TestInjection(t *testing.T) {
+	c, cmdbuf := fakeClient(t, "+OK good morning\n")
+	if err := c.User("x\r\nDELE 1"); err != ErrInvalidArgument {
+		t.Fatalf("User returned %v, expected ErrInvalidArgument", err)
+	}
+	if err := c.Pass("x\nDELE 1"); err != ErrInvalidArgument {
+		t.Fatalf("Pass returned %v, expected ErrInvalidArgument", err)
+	}
+	if err := c.Auth("uname", "x\rDELE 1"); err != ErrInvalidArgument {
+		t.Fatalf("Auth returned %v, expected ErrInvalidArgument", err)
+	}
+	if err := c.SetLanguage("de\r\nDELE 1"); err != ErrInvalidArgument {
+		t.Fatalf("SetLanguage returned %v, expected ErrInvalidArgument", err)
+	}
+	if cmdbuf.Len() != 0 {
+		t.Fatalf("Sent %q", cmdbuf.String())
+	}
+}
+
+func TestListLanguages(t *testing.T) {
+	c, _ := fakeClient(t, "+OK good morning\n+OK Language listing follows\nen English\nde Deutsch\ni-default\n.\n")
+	langs, err := c.ListLanguages()
+	if err != nil {
+		t.Fatalf("ListLanguages failed: %s", err)
+	}
+	expected := []Language{{"en", "English"}, {"de", "Deutsch"}, {"i-default", ""}}
+	if len(langs) != len(expected) {
+		t.Fatalf("Got %+v, expected %+v", langs, expected)
+	}
+	for i := range langs {
+		if langs[i] != expected[i] {
+			t.Fatalf("Got %+v, expected %+v", langs, expected)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	c, _ := fakeSession(t, errorServer)
+
+	if err := c.SetLanguage("de"); err != nil {
+		t.Fatalf("SetLanguage failed: %s", err)
+	}
+	_, err := c.Retr(1)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Retr returned %#v, expected *Error", err)
+	}
+	if e.Code != "SYS/TEMP" || e.Text != "Nachricht nicht lesbar" || e.Lang != "de" {
+		t.Fatalf("Got %#v", e)
+	}
+	if e.Error() != "[SYS/TEMP] Nachricht nicht lesbar" {
+		t.Fatalf("Got %q", e.Error())
+	}
+}
+
+var errorServer = `+OK good morning
++OK send PASS
++OK welcome
++OK de Sprache geändert
+-ERR [SYS/TEMP] Nachricht nicht lesbar
+`
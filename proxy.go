@@ -0,0 +1,142 @@
+package pop3
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// DialWithProxy creates an unsecured connection to the POP3 server at the
+// given address through the proxy at proxyURL and returns the corresponding
+// Client.
+//
+// The socks5 and socks5h schemes are supported through
+// golang.org/x/net/proxy, as are the http and https schemes, which tunnel
+// the connection through an HTTP CONNECT proxy. Credentials may be given in
+// the user info part of the URL.
+func DialWithProxy(proxyURL, addr string, opts ...Option) (*Client, error) {
+	conn, err := dialProxy(proxyURL, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opts...)
+}
+
+// DialTLSWithProxy is like DialWithProxy, but secures the connection to the
+// POP3 server with TLS, as DialTLS does.
+func DialTLSWithProxy(proxyURL, addr string, config *tls.Config, opts ...Option) (*Client, error) {
+	conn, err := dialProxy(proxyURL, addr)
+	if err != nil {
+		return nil, err
+	}
+	tconn := tls.Client(conn, serverConfig(config, addr))
+	if err = tconn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return NewClient(tconn, opts...)
+}
+
+// serverConfig returns config with ServerName defaulting to the host of addr,
+// the way tls.Dial fills it in.
+func serverConfig(config *tls.Config, addr string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName != "" {
+		return config
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	config = config.Clone()
+	config.ServerName = host
+	return config
+}
+
+func dialProxy(proxyURL, addr string) (net.Conn, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	var d proxy.Dialer
+	switch u.Scheme {
+	case "http", "https":
+		d = connectDialer{u}
+	default:
+		d, err = proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d.Dial("tcp", addr)
+}
+
+// connectDialer tunnels connections through an HTTP proxy using CONNECT.
+type connectDialer struct {
+	u *url.URL
+}
+
+func (d connectDialer) Dial(network, addr string) (net.Conn, error) {
+	host := d.u.Host
+	if d.u.Port() == "" {
+		if d.u.Scheme == "https" {
+			host = net.JoinHostPort(d.u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(d.u.Hostname(), "80")
+		}
+	}
+	conn, err := net.Dial(network, host)
+	if err != nil {
+		return nil, err
+	}
+	if d.u.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: d.u.Hostname()})
+	}
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.u.User != nil {
+		password, _ := d.u.User.Password()
+		auth := d.u.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, errors.New("Proxy refused connection: " + resp.Status)
+	}
+	// The server greeting may have arrived along with the proxy's response.
+	return bufferedConn{conn, br}, nil
+}
+
+// bufferedConn is a net.Conn reading through a bufio.Reader wrapping it.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,95 @@
+package pop3
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// connectProxy accepts a single CONNECT request on l, passes it to check and
+// answers with response, followed immediately by greeting if it is not
+// empty. If tlsCert is given, the tunnel is terminated with TLS when the
+// response is 200.
+func connectProxy(l net.Listener, response, greeting string, tlsCert *tls.Certificate, check func(*http.Request)) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return
+	}
+	check(req)
+	if tlsCert == nil {
+		// The response and the greeting arrive in the same packet.
+		conn.Write([]byte(response + greeting))
+		buf := make([]byte, 1)
+		conn.Read(buf)
+		return
+	}
+	conn.Write([]byte(response))
+	tconn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*tlsCert}})
+	tconn.Write([]byte(greeting))
+	buf := make([]byte, 1)
+	tconn.Read(buf)
+}
+
+func TestDialWithProxy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	reqs := make(chan *http.Request, 1)
+	go connectProxy(l, "HTTP/1.1 200 Connection established\r\n\r\n", "+OK good morning\r\n", nil, func(req *http.Request) { reqs <- req })
+	c, err := DialWithProxy("http://puser:psecret@"+l.Addr().String(), "pop.example.com:110")
+	if err != nil {
+		t.Fatalf("DialWithProxy failed: %s", err)
+	}
+	defer c.Close()
+	if c.Greeting() != "good morning" {
+		t.Fatalf("Got greeting %q", c.Greeting())
+	}
+	req := <-reqs
+	if req.Method != "CONNECT" || req.RequestURI != "pop.example.com:110" || req.Host != "pop.example.com:110" {
+		t.Fatalf("Got request %s %s, Host %s", req.Method, req.RequestURI, req.Host)
+	}
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("puser:psecret"))
+	if got := req.Header.Get("Proxy-Authorization"); got != auth {
+		t.Fatalf("Got Proxy-Authorization %q, expected %q", got, auth)
+	}
+
+	go connectProxy(l, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n", "", nil, func(*http.Request) {})
+	if _, err = DialWithProxy("http://"+l.Addr().String(), "pop.example.com:110"); err == nil || !strings.Contains(err.Error(), "407") {
+		t.Fatalf("DialWithProxy returned %v, expected the 407 status", err)
+	}
+}
+
+func TestDialTLSWithProxy(t *testing.T) {
+	tlsCert, cert := selfSigned(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go connectProxy(l, "HTTP/1.1 200 Connection established\r\n\r\n", "+OK secure morning\r\n", &tlsCert, func(*http.Request) {})
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	// The ServerName is taken from the address, not the proxy URL.
+	c, err := DialTLSWithProxy("http://"+l.Addr().String(), "pop.example.com:995", &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatalf("DialTLSWithProxy failed: %s", err)
+	}
+	defer c.Close()
+	if c.Greeting() != "secure morning" {
+		t.Fatalf("Got greeting %q", c.Greeting())
+	}
+}
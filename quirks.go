@@ -0,0 +1,39 @@
+package pop3
+
+// Quirks is a set of workarounds for servers deviating from RFC 1939, to be
+// enabled with WithQuirks when strict parsing fails.
+type Quirks uint
+
+const (
+	// QuirkGreeting accepts a greeting which does not start with +OK, as
+	// long as it is not -ERR.
+	QuirkGreeting Quirks = 1 << iota
+	// QuirkListFields ignores fields after the unique-id in UIDL listings,
+	// skips lines of LIST and UIDL listings which cannot be parsed instead
+	// of failing, and accepts the response to LIST for a single message if
+	// it only contains the size.
+	QuirkListFields
+	// QuirkNoCapa makes Caps return an empty list, rather than an error,
+	// if the server rejects CAPA.
+	QuirkNoCapa
+	// QuirkBareLF accepts lines terminated by LF instead of CRLF.
+	QuirkBareLF
+	// QuirkMissingDot accepts a multi-line response cut short by the server
+	// closing the connection before the terminating line.
+	QuirkMissingDot
+
+	// LenientParsing enables all quirks.
+	LenientParsing = QuirkGreeting | QuirkListFields | QuirkNoCapa | QuirkBareLF | QuirkMissingDot
+)
+
+// DefaultQuirks are the quirks enabled unless WithQuirks is given. Only
+// QuirkBareLF is enabled, since earlier versions always accepted bare LF.
+const DefaultQuirks = QuirkBareLF
+
+// WithQuirks sets the quirks enabled for the Client, replacing
+// DefaultQuirks.
+func WithQuirks(q Quirks) Option {
+	return func(c *Client) {
+		c.quirks = q
+	}
+}
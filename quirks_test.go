@@ -0,0 +1,102 @@
+package pop3
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// rawClient is like fakeClient, but leaves the line endings of server alone.
+func rawClient(server string, opts ...Option) (*Client, error) {
+	var fake faker
+	fake.ReadWriter = struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(server), io.Discard}
+	return NewClient(fake, opts...)
+}
+
+func TestQuirks(t *testing.T) {
+	if _, err := rawClient("POP3 server ready\r\n"); err == nil {
+		t.Fatal("NewClient accepted greeting without +OK")
+	}
+	if _, err := rawClient("POP3 server ready\r\n", WithQuirks(QuirkGreeting)); err != nil {
+		t.Fatalf("NewClient failed with QuirkGreeting: %s", err)
+	}
+	if _, err := rawClient("-ERR go away\r\n", WithQuirks(LenientParsing)); err == nil {
+		t.Fatal("NewClient accepted -ERR greeting")
+	}
+
+	bareLF := "+OK good morning\r\n+OK\r\nTOP\nUIDL\n.\n"
+	c, _ := rawClient(bareLF, WithQuirks(0))
+	if _, err := c.Caps(); err != ErrInvalidResponse {
+		t.Fatalf("Caps returned %v for bare LF, expected ErrInvalidResponse", err)
+	}
+	c, _ = rawClient(bareLF)
+	if caps, err := c.Caps(); err != nil || len(caps) != 2 {
+		t.Fatalf("Caps returned %q, %v with default quirks", caps, err)
+	}
+
+	c, _ = rawClient("+OK good morning\r\n+OK\r\n+OK\r\n+OK 1 octets\nline\r\n.\r\n+OK 1 1\r\n", WithQuirks(0))
+	c.User("uname")
+	c.Pass("password")
+	if _, err := c.Retr(1); err != ErrInvalidResponse {
+		t.Fatalf("Retr returned %v for bare LF status line, expected ErrInvalidResponse", err)
+	}
+	if c.State() != StateClosed {
+		t.Fatalf("Got state %s after bare LF status line", c.State())
+	}
+	if _, _, err := c.Stat(); err != ErrClosed {
+		t.Fatalf("Stat returned %v after bare LF status line, expected ErrClosed", err)
+	}
+
+	c, _ = rawClient("+OK good morning\r\n-ERR unknown command\r\n", WithQuirks(QuirkNoCapa))
+	if caps, err := c.Caps(); err != nil || len(caps) != 0 {
+		t.Fatalf("Caps returned %q, %v with QuirkNoCapa", caps, err)
+	}
+
+	short := "+OK good morning\r\n+OK\r\n+OK\r\n+OK\r\nSubject: cut short\r\n"
+	c, _ = rawClient(short)
+	c.User("uname")
+	c.Pass("password")
+	if _, err := c.Retr(1); err == nil {
+		t.Fatal("Retr accepted missing terminating line")
+	}
+	c, _ = rawClient(short, WithQuirks(QuirkMissingDot))
+	c.User("uname")
+	c.Pass("password")
+	if text, err := c.Retr(1); err != nil || text != "Subject: cut short" {
+		t.Fatalf("Retr returned %q, %v with QuirkMissingDot", text, err)
+	}
+
+	list := "+OK good morning\r\n+OK\r\n+OK\r\n+OK\r\n1 120\r\n2 deleted\r\n3 140 octets\r\n.\r\n"
+	c, _ = rawClient(list)
+	c.User("uname")
+	c.Pass("password")
+	if _, err := c.ListMessages(); err == nil {
+		t.Fatal("ListMessages accepted malformed line")
+	}
+	c, _ = rawClient(list+"-ERR no UIDL\r\n", WithQuirks(QuirkListFields))
+	c.User("uname")
+	c.Pass("password")
+	if msgs, err := c.ListMessages(); err != nil || len(msgs) != 2 || msgs[1].Size != 140 {
+		t.Fatalf("ListMessages returned %v, %v with QuirkListFields", msgs, err)
+	}
+
+	uidl := "+OK good morning\r\n+OK\r\n+OK\r\n+OK\r\n1 abc extra\r\n.\r\n"
+	c, _ = rawClient(uidl)
+	c.User("uname")
+	c.Pass("password")
+	if _, _, err := c.UidlAll(); err == nil {
+		t.Fatal("UidlAll accepted extra fields")
+	}
+	c, _ = rawClient(uidl+"+OK\r\n1 10\r\n.\r\n+OK\r\n1 abc extra\r\n.\r\n", WithQuirks(QuirkListFields))
+	c.User("uname")
+	c.Pass("password")
+	if _, uids, err := c.UidlAll(); err != nil || len(uids) != 1 || uids[0] != "abc" {
+		t.Fatalf("UidlAll returned %q, %v with QuirkListFields", uids, err)
+	}
+	if msgs, err := c.ListMessages(); err != nil || len(msgs) != 1 || msgs[0].UID != "abc" {
+		t.Fatalf("ListMessages returned %v, %v with QuirkListFields", msgs, err)
+	}
+}
@@ -0,0 +1,82 @@
+package pop3
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// WithRateLimit throttles the data received from the server to about
+// bytesPerSec octets per second on average, for example to keep bulk
+// downloads with Retr from saturating a shared link. Up to one second's
+// worth of data may be received at full speed after a pause. A
+// non-positive bytesPerSec disables the limit.
+func WithRateLimit(bytesPerSec int) Option {
+	return func(c *Client) {
+		c.readLimit = nil
+		if bytesPerSec > 0 {
+			c.readLimit = newBucket(float64(bytesPerSec), float64(bytesPerSec))
+		}
+	}
+}
+
+// WithCommandRateLimit limits the commands sent to the server to n per the
+// given period, delaying further commands until the limit allows them.
+// Servers may penalize clients issuing commands too quickly. A
+// non-positive n or period disables the limit.
+func WithCommandRateLimit(n int, per time.Duration) Option {
+	return func(c *Client) {
+		c.cmdLimit = nil
+		if n > 0 && per > 0 {
+			c.cmdLimit = newBucket(float64(n)/per.Seconds(), float64(n))
+		}
+	}
+}
+
+// A bucket is a token bucket, refilled at rate tokens per second up to
+// burst tokens.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, burst float64) *bucket {
+	return &bucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait takes n tokens from the bucket, sleeping as long as the bucket is in
+// debt afterwards.
+func (b *bucket) wait(n int) {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+	time.Sleep(d)
+}
+
+// limitedReader throttles reads from r with b.
+type limitedReader struct {
+	r io.Reader
+	b *bucket
+}
+
+func (l limitedReader) Read(p []byte) (int, error) {
+	if max := int(l.b.burst); max > 0 && len(p) > max {
+		p = p[:max]
+	}
+	n, err := l.r.Read(p)
+	l.b.wait(n)
+	return n, err
+}
@@ -0,0 +1,43 @@
+package pop3
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	body := strings.Repeat("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcde\n", 250)
+	c, _ := fakeSession(t, "+OK\n+OK\n+OK\n+OK\n"+body+".\n", WithRateLimit(10000))
+	start := time.Now()
+	if _, err := c.Retr(1); err != nil {
+		t.Fatalf("Retr failed: %s", err)
+	}
+	// About 16000 octets, of which the first 10000 arrive at once.
+	if d := time.Since(start); d < 500*time.Millisecond {
+		t.Fatalf("Retr took %s", d)
+	}
+}
+
+func TestCommandRateLimit(t *testing.T) {
+	start := time.Now()
+	c, _ := fakeSession(t, "+OK\n+OK\n+OK\n"+strings.Repeat("+OK\n", 5), WithCommandRateLimit(2, 100*time.Millisecond))
+	for i := 0; i < 5; i++ {
+		if err := c.Noop(); err != nil {
+			t.Fatalf("Noop failed: %s", err)
+		}
+	}
+	// 7 commands, of which the first 2 are sent at once.
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatalf("Commands took %s", d)
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	for _, opt := range []Option{WithRateLimit(0), WithRateLimit(-1), WithCommandRateLimit(0, time.Second), WithCommandRateLimit(1, 0)} {
+		c, _ := fakeClient(t, "+OK good morning\n", opt)
+		if c.readLimit != nil || c.cmdLimit != nil {
+			t.Fatal("Non-positive limit was not disabled")
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package pop3
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewRecorder returns a connection which passes all traffic through to conn
+// and writes a transcript of it to w, for replaying with a Replayer. Every
+// line sent is written prefixed with "C: " and every line received prefixed
+// with "S: ", in the same format as WithTrace, and with the same masking of
+// passwords and authentication responses. Line endings are not recorded.
+//
+// To record a session secured with TLS, wrap the connection returned by
+// tls.Dial; sessions upgraded with StartTLS cannot be recorded in clear
+// text. Errors writing to w are ignored.
+func NewRecorder(conn net.Conn, w io.Writer) net.Conn {
+	return &recorder{Conn: conn, w: w}
+}
+
+type recorder struct {
+	net.Conn
+	w io.Writer
+
+	mu       sync.Mutex
+	sent     []byte
+	received []byte
+	// response is set while the first line of a response is awaited, and
+	// sasl while the next line sent is an authentication response.
+	response, auth, sasl bool
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.sent = append(r.sent, p...)
+	for {
+		i := bytes.IndexByte(r.sent, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(r.sent[:i+1])
+		r.sent = r.sent[i+1:]
+		verb := strings.ToUpper(strings.SplitN(strings.TrimRight(line, "\r\n"), " ", 2)[0])
+		if r.sasl {
+			line = "********"
+		} else {
+			r.auth = verb == "AUTH"
+			line = redact(line)
+		}
+		io.WriteString(r.w, "C: "+strings.TrimRight(line, "\r\n")+"\n")
+		r.response = true
+	}
+	r.mu.Unlock()
+	return r.Conn.Write(p)
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	r.mu.Lock()
+	r.received = append(r.received, p[:n]...)
+	for {
+		i := bytes.IndexByte(r.received, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(r.received[:i+1]), "\r\n")
+		r.received = r.received[i+1:]
+		if r.response {
+			r.sasl = (r.auth || r.sasl) && (line == "+" || strings.HasPrefix(line, "+ "))
+			r.auth = r.auth && r.sasl
+			r.response = false
+		}
+		io.WriteString(r.w, "S: "+line+"\n")
+	}
+	r.mu.Unlock()
+	return n, err
+}
+
+// A Replayer is a connection serving a transcript recorded with NewRecorder
+// or WithTrace back to a Client, for regression tests against sessions
+// captured from real servers. Lines received from the server are sent with
+// CRLF line endings once every line the client sent before them has been
+// received. Lines sent by the client are compared with the transcript after
+// masking; a masked transcript line matches any line.
+//
+// A line the client sends which does not match the transcript fails the
+// Write, and is reported by Verify along with any part of the transcript
+// left unplayed.
+type Replayer struct {
+	mu      sync.Mutex
+	lines   []transcriptLine
+	pos     int
+	sent    []byte
+	pending bytes.Buffer
+	err     error
+	closed  bool
+}
+
+type transcriptLine struct {
+	client bool
+	text   string
+	num    int
+}
+
+// NewReplayer reads a transcript from r and returns a Replayer serving it.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	rp := &Replayer{}
+	s := bufio.NewScanner(r)
+	for num := 1; s.Scan(); num++ {
+		l := strings.TrimSuffix(s.Text(), "\r")
+		switch {
+		case strings.HasPrefix(l, "C: "):
+			rp.lines = append(rp.lines, transcriptLine{true, l[3:], num})
+		case strings.HasPrefix(l, "S: "):
+			rp.lines = append(rp.lines, transcriptLine{false, l[3:], num})
+		case l == "S:":
+			// An empty line, with its trailing space stripped by an editor.
+			rp.lines = append(rp.lines, transcriptLine{false, "", num})
+		default:
+			return nil, fmt.Errorf("Transcript line %d: expected C: or S: prefix", num)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return rp, nil
+}
+
+func (rp *Replayer) Read(p []byte) (int, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.closed {
+		return 0, net.ErrClosed
+	}
+	for rp.pending.Len() == 0 && rp.pos < len(rp.lines) && !rp.lines[rp.pos].client {
+		rp.pending.WriteString(rp.lines[rp.pos].text + "\r\n")
+		rp.pos++
+	}
+	if rp.pending.Len() == 0 {
+		if rp.pos < len(rp.lines) {
+			l := rp.lines[rp.pos]
+			return 0, rp.mismatch(fmt.Errorf("Transcript line %d: client read, expected it to send %q", l.num, l.text))
+		}
+		return 0, io.EOF
+	}
+	return rp.pending.Read(p)
+}
+
+func (rp *Replayer) Write(p []byte) (int, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.closed {
+		return 0, net.ErrClosed
+	}
+	rp.sent = append(rp.sent, p...)
+	for {
+		i := bytes.IndexByte(rp.sent, '\n')
+		if i < 0 {
+			break
+		}
+		got := strings.TrimRight(string(rp.sent[:i+1]), "\r\n")
+		rp.sent = rp.sent[i+1:]
+		if rp.pos >= len(rp.lines) || !rp.lines[rp.pos].client {
+			return 0, rp.mismatch(fmt.Errorf("Client sent %q after the end of its part of the transcript", redact(got)))
+		}
+		l := rp.lines[rp.pos]
+		if l.text != "********" && l.text != strings.TrimRight(redact(got+"\r\n"), "\r\n") {
+			return 0, rp.mismatch(fmt.Errorf("Transcript line %d: client sent %q, expected %q", l.num, redact(got), l.text))
+		}
+		rp.pos++
+	}
+	return len(p), nil
+}
+
+// mismatch records err as the first divergence from the transcript.
+func (rp *Replayer) mismatch(err error) error {
+	if rp.err == nil {
+		rp.err = err
+	}
+	return rp.err
+}
+
+// Verify returns the first divergence from the transcript, or an error if
+// the transcript has not been played to its end.
+func (rp *Replayer) Verify() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.err != nil {
+		return rp.err
+	}
+	if rp.pos < len(rp.lines) || rp.pending.Len() > 0 {
+		num := rp.lines[len(rp.lines)-1].num
+		if rp.pos < len(rp.lines) {
+			num = rp.lines[rp.pos].num
+		}
+		return fmt.Errorf("Transcript line %d: not played", num)
+	}
+	return nil
+}
+
+func (rp *Replayer) Close() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.closed = true
+	return nil
+}
+
+type replayAddr struct{}
+
+func (replayAddr) Network() string { return "replay" }
+func (replayAddr) String() string  { return "replay" }
+
+func (rp *Replayer) LocalAddr() net.Addr                { return replayAddr{} }
+func (rp *Replayer) RemoteAddr() net.Addr               { return replayAddr{} }
+func (rp *Replayer) SetDeadline(t time.Time) error      { return nil }
+func (rp *Replayer) SetReadDeadline(t time.Time) error  { return nil }
+func (rp *Replayer) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,111 @@
+package pop3
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+var replayTests = []struct {
+	file  string
+	login func(c *Client) error
+}{
+	{"testdata/dovecot.txt", func(c *Client) error { return c.Auth("user@example.com", "secret") }},
+	{"testdata/gmail.txt", func(c *Client) error { return c.XOAuth2("user@example.com", "ya29.token") }},
+	{"testdata/exchange.txt", func(c *Client) error { return c.Auth("user@example.com", "secret") }},
+}
+
+func TestReplay(t *testing.T) {
+	for _, tt := range replayTests {
+		f, err := os.Open(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rp, err := NewReplayer(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: NewReplayer failed: %s", tt.file, err)
+		}
+		c, err := NewClient(rp)
+		if err != nil {
+			t.Fatalf("%s: NewClient failed: %s", tt.file, err)
+		}
+		if err = tt.login(c); err != nil {
+			t.Fatalf("%s: Login failed: %s", tt.file, err)
+		}
+		if _, err = c.ListMessages(); err != nil {
+			t.Fatalf("%s: ListMessages failed: %s", tt.file, err)
+		}
+		text, err := c.Retr(1)
+		if err != nil {
+			t.Fatalf("%s: Retr failed: %s", tt.file, err)
+		}
+		if !strings.Contains(text, "\nSubject: Lunch\n") {
+			t.Fatalf("%s: Got:\n%s", tt.file, text)
+		}
+		if err = c.Quit(); err != nil {
+			t.Fatalf("%s: Quit failed: %s", tt.file, err)
+		}
+		if err = rp.Verify(); err != nil {
+			t.Fatalf("%s: %s", tt.file, err)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	server, err := NewReplayer(strings.NewReader(recorderTranscript))
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %s", err)
+	}
+	var transcript bytes.Buffer
+	c, err := NewClient(NewRecorder(server, &transcript))
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	if err = c.Auth("uname", "password"); err != nil {
+		t.Fatalf("Auth failed: %s", err)
+	}
+	if err = c.Noop(); err != nil {
+		t.Fatalf("Noop failed: %s", err)
+	}
+	if err = c.Quit(); err != nil {
+		t.Fatalf("Quit failed: %s", err)
+	}
+	if transcript.String() != recorderTranscript {
+		t.Fatalf("Got:\n%s\nExpected:\n%s", transcript.String(), recorderTranscript)
+	}
+
+	rp, err := NewReplayer(&transcript)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %s", err)
+	}
+	c, err = NewClient(rp)
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	if err = c.Auth("uname", "password"); err != nil {
+		t.Fatalf("Auth failed: %s", err)
+	}
+	if err = c.Dele(1); err == nil {
+		t.Fatal("Dele succeeded, expected a mismatch")
+	}
+	if err = rp.Verify(); err == nil || !strings.Contains(err.Error(), `sent "DELE 1", expected "NOOP"`) {
+		t.Fatalf("Verify returned %v", err)
+	}
+}
+
+var recorderTranscript = `S: +OK good morning
+C: CAPA
+S: +OK
+S: SASL CRAM-MD5
+S: .
+C: AUTH CRAM-MD5
+S: + PDE4OTYuNjk3MTcwOTUyQHBvc3RvZmZpY2UucmVzdG9uLm1jaS5uZXQ+
+C: ********
+S: +OK welcome
+C: NOOP
+S: +OK
+C: QUIT
+S: +OK bye
+`
@@ -0,0 +1,238 @@
+package pop3
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ResilientClient wraps a Client, re-dialing and re-authenticating when the
+// connection drops and transparently retrying the idempotent commands STAT,
+// LIST, UIDL and RETR.
+//
+// Messages marked for deletion are unmarked by the server when a session is
+// lost, so Dele is never retried. Message numbers are only stable across
+// sessions if nothing else modifies the maildrop in between; callers that
+// need certainty should key off unique-ids.
+//
+// A ResilientClient may be used by multiple goroutines at once.
+type ResilientClient struct {
+	// Dial opens a new connection to the server. It is called for the
+	// initial connection and after every drop.
+	Dial func() (*Client, error)
+
+	Username string
+	Password string
+
+	// MaxRetries is the number of times an operation is retried after the
+	// connection drops before its error is returned.
+	MaxRetries int
+
+	// Backoff returns how long to wait before the given retry, counting
+	// from 1.
+	Backoff func(attempt int) time.Duration
+
+	mu sync.Mutex
+	c  *Client
+}
+
+// NewResilientClient connects and authenticates using dial, retrying with the
+// default policy of 3 retries and exponential backoff between one and thirty
+// seconds. The policy may be changed through the exported fields afterwards.
+func NewResilientClient(dial func() (*Client, error), username, password string) (*ResilientClient, error) {
+	r := &ResilientClient{
+		Dial:       dial,
+		Username:   username,
+		Password:   password,
+		MaxRetries: 3,
+		Backoff:    ExponentialBackoff(time.Second, 30*time.Second),
+	}
+	if err := r.retry(func(*Client) error { return nil }); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ExponentialBackoff returns a backoff policy which waits base before the
+// first retry and doubles the delay for every following one, up to max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// Client returns the underlying Client of the current session, or nil if
+// the connection is down and has not been re-established yet.
+func (r *ResilientClient) Client() *Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.c
+}
+
+func (r *ResilientClient) connect() error {
+	if r.c != nil {
+		return nil
+	}
+	c, err := r.Dial()
+	if err != nil {
+		return err
+	}
+	if err = c.Auth(r.Username, r.Password); err != nil {
+		c.Close()
+		return err
+	}
+	r.c = c
+	return nil
+}
+
+func (r *ResilientClient) drop() {
+	if r.c != nil {
+		r.c.Close()
+		r.c = nil
+	}
+}
+
+// retry runs fn against a connected and authenticated Client, reconnecting
+// and running it again as long as it fails with a connection error.
+func (r *ResilientClient) retry(fn func(*Client) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && r.Backoff != nil {
+			time.Sleep(r.Backoff(attempt))
+		}
+		err := r.connect()
+		if err == nil {
+			err = fn(r.c)
+			if r.c.State() == StateClosed {
+				r.drop()
+			}
+			if err == nil {
+				return nil
+			}
+		}
+		if !isConnError(err) {
+			return err
+		}
+		r.drop()
+		if attempt >= r.MaxRetries {
+			return err
+		}
+	}
+}
+
+// isConnError reports whether err indicates that the connection to the
+// server has been lost or could not be established, as opposed to the
+// server rejecting a command.
+func isConnError(err error) bool {
+	var ce *ConnError
+	var ne net.Error
+	return errors.As(err, &ce) || errors.As(err, &ne)
+}
+
+// Stat is like Client.Stat, retrying if the connection drops.
+func (r *ResilientClient) Stat() (count, size int, err error) {
+	err = r.retry(func(c *Client) (err error) {
+		count, size, err = c.Stat()
+		return
+	})
+	return
+}
+
+// List is like Client.List, retrying if the connection drops.
+func (r *ResilientClient) List(msg int) (size int, err error) {
+	err = r.retry(func(c *Client) (err error) {
+		size, err = c.List(msg)
+		return
+	})
+	return
+}
+
+// ListAll is like Client.ListAll, retrying if the connection drops.
+func (r *ResilientClient) ListAll() (msgs []int, sizes []int, err error) {
+	err = r.retry(func(c *Client) (err error) {
+		msgs, sizes, err = c.ListAll()
+		return
+	})
+	return
+}
+
+// ListMessages is like Client.ListMessages, retrying if the connection drops.
+func (r *ResilientClient) ListMessages() (msgs []MessageInfo, err error) {
+	err = r.retry(func(c *Client) (err error) {
+		msgs, err = c.ListMessages()
+		return
+	})
+	return
+}
+
+// Uidl is like Client.Uidl, retrying if the connection drops.
+func (r *ResilientClient) Uidl(msg int) (uid string, err error) {
+	err = r.retry(func(c *Client) (err error) {
+		uid, err = c.Uidl(msg)
+		return
+	})
+	return
+}
+
+// UidlAll is like Client.UidlAll, retrying if the connection drops.
+func (r *ResilientClient) UidlAll() (msgs []int, uids []string, err error) {
+	err = r.retry(func(c *Client) (err error) {
+		msgs, uids, err = c.UidlAll()
+		return
+	})
+	return
+}
+
+// Retr is like Client.Retr, retrying if the connection drops. A partially
+// downloaded message is discarded and downloaded again in full.
+func (r *ResilientClient) Retr(msg int) (text string, err error) {
+	err = r.retry(func(c *Client) (err error) {
+		text, err = c.Retr(msg)
+		return
+	})
+	return
+}
+
+// Dele marks the given message as deleted. It is not retried, since the
+// marks of a dropped session are lost along with it.
+func (r *ResilientClient) Dele(msg int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.connect(); err != nil {
+		return err
+	}
+	err := r.c.Dele(msg)
+	if r.c.State() == StateClosed {
+		r.drop()
+	}
+	return err
+}
+
+// Quit ends the current session, if any, committing deletions.
+func (r *ResilientClient) Quit() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.c == nil {
+		return nil
+	}
+	err := r.c.Quit()
+	r.c = nil
+	return err
+}
+
+// Close ends the current session, if any, without committing deletions.
+func (r *ResilientClient) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.drop()
+	return nil
+}
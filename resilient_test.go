@@ -0,0 +1,48 @@
+package pop3
+
+import "testing"
+
+func TestResilientRetr(t *testing.T) {
+	scripts := []string{resilientDropped, resilientServer}
+	r, err := NewResilientClient(func() (*Client, error) {
+		c, _ := fakeClient(t, scripts[0])
+		scripts = scripts[1:]
+		return c, nil
+	}, "uname", "password")
+	if err != nil {
+		t.Fatalf("NewResilientClient failed: %s", err)
+	}
+	r.Backoff = nil
+
+	text, err := r.Retr(1)
+	if err != nil {
+		t.Fatalf("Retr failed: %s", err)
+	}
+	if text != "Subject: hi\n\nbody" {
+		t.Fatalf("Got:\n%s", text)
+	}
+	if len(scripts) != 0 {
+		t.Fatal("Retr did not reconnect")
+	}
+}
+
+var resilientDropped = `+OK good morning
++OK
+.
++OK send PASS
++OK welcome
++OK message follows
+Subject: hi
+`
+
+var resilientServer = `+OK good morning
++OK
+.
++OK send PASS
++OK welcome
++OK message follows
+Subject: hi
+
+body
+.
+`
@@ -0,0 +1,60 @@
+package pop3
+
+import "time"
+
+// DeleteRetrieved marks the messages with the given unique-ids as deleted,
+// typically those already retrieved and stored by the caller. Unique-ids no
+// longer present in the maildrop are ignored. It returns the number of
+// messages marked; as with Dele, they are only removed once Quit succeeds.
+// If uids is not empty, the server must support UIDL, or DeleteRetrieved
+// fails with ErrNoUIDL.
+func (c *Client) DeleteRetrieved(uids []string) (deleted int, err error) {
+	msgs, err := c.ListMessages()
+	if err != nil {
+		return 0, err
+	}
+	if len(uids) > 0 {
+		for _, m := range msgs {
+			if m.UID == "" {
+				return 0, ErrNoUIDL
+			}
+		}
+	}
+	want := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		want[uid] = true
+	}
+	for _, m := range msgs {
+		if !want[m.UID] {
+			continue
+		}
+		if err = c.Dele(m.Seq); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// DeleteOlderThan marks the messages whose Date header lies more than d in
+// the past as deleted, implementing the common policy of leaving mail on
+// the server for a number of days. Headers are fetched with ScanHeaders;
+// messages without a valid Date header are kept. It returns the number of
+// messages marked; as with Dele, they are only removed once Quit succeeds.
+func (c *Client) DeleteOlderThan(d time.Duration) (deleted int, err error) {
+	headers, err := c.ScanHeaders([]string{"Date"})
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-d)
+	for _, h := range headers {
+		if date, err := h.Header.Date(); err != nil || !date.Before(cutoff) {
+			continue
+		}
+		if err = c.Dele(h.Seq); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
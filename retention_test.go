@@ -0,0 +1,108 @@
+package pop3
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteOlderThan(t *testing.T) {
+	old := time.Now().Add(-10 * 24 * time.Hour).Format(time.RFC1123Z)
+	recent := time.Now().Add(-time.Hour).Format(time.RFC1123Z)
+	server := strings.NewReplacer("OLD", old, "RECENT", recent).Replace(retentionServer)
+
+	c, cmdbuf := fakeSession(t, server)
+	cmdbuf.Reset()
+	deleted, err := c.DeleteOlderThan(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("DeleteOlderThan failed: %s", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("Deleted %d messages, expected 1", deleted)
+	}
+	expected := strings.Join(strings.Split(retentionClient, "\n"), "\r\n")
+	if cmdbuf.String() != expected {
+		t.Fatalf("Got:\n%s\nExpected:\n%s", cmdbuf.String(), expected)
+	}
+}
+
+var retentionServer = `+OK good morning
++OK send PASS
++OK welcome
++OK 3 messages
+1 120
+2 130
+3 140
+.
++OK
+1 a
+2 b
+3 c
+.
+-ERR unknown command
++OK
+Date: OLD
+Subject: old
+
+.
++OK
+Date: RECENT
+
+.
++OK
+Subject: undated
+
+.
++OK 1 deleted
+`
+
+var retentionClient = `LIST
+UIDL
+XTND XLST Date
+TOP 1 0
+TOP 2 0
+TOP 3 0
+DELE 1
+`
+
+func TestDeleteRetrieved(t *testing.T) {
+	c, cmdbuf := fakeSession(t, `+OK good morning
++OK send PASS
++OK welcome
++OK 3 messages
+1 120
+2 130
+3 140
+.
++OK
+1 a
+2 b
+3 c
+.
++OK 1 deleted
++OK 3 deleted
+`)
+	cmdbuf.Reset()
+	deleted, err := c.DeleteRetrieved([]string{"c", "gone", "a"})
+	if err != nil {
+		t.Fatalf("DeleteRetrieved failed: %s", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("Deleted %d messages, expected 2", deleted)
+	}
+	if expected := "LIST\r\nUIDL\r\nDELE 1\r\nDELE 3\r\n"; cmdbuf.String() != expected {
+		t.Fatalf("Sent %q, expected %q", cmdbuf.String(), expected)
+	}
+}
+
+func TestDeleteRetrievedNoUIDL(t *testing.T) {
+	c, cmdbuf := fakeSession(t, "+OK good morning\n+OK send PASS\n+OK welcome\n"+
+		"+OK 1 messages\n1 120\n.\n-ERR unknown command\n")
+	cmdbuf.Reset()
+	if _, err := c.DeleteRetrieved([]string{"a"}); err != ErrNoUIDL {
+		t.Fatalf("DeleteRetrieved returned %v, expected ErrNoUIDL", err)
+	}
+	if expected := "LIST\r\nUIDL\r\n"; cmdbuf.String() != expected {
+		t.Fatalf("Sent %q, expected %q", cmdbuf.String(), expected)
+	}
+}
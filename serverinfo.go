@@ -0,0 +1,79 @@
+package pop3
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Greeting returns the text of the greeting the server sent on connecting,
+// following "+OK ".
+func (c *Client) Greeting() string {
+	return c.greeting
+}
+
+// ServerInfo describes the server software, as far as it can be told from
+// the greeting and capabilities.
+type ServerInfo struct {
+	// Software is the name of the server software, such as "Dovecot", or
+	// empty if it is not recognized.
+	Software string
+	// Version is the version of the software, if announced.
+	Version string
+	// Greeting is the text of the greeting, as returned by Greeting.
+	Greeting string
+	// Implementation is the argument of the IMPLEMENTATION capability, if
+	// the server announces it.
+	Implementation string
+}
+
+// serverSignatures maps text found in greetings and implementation strings
+// to the software sending it. The version is taken from the greeting only
+// for software known to announce it there.
+var serverSignatures = []struct {
+	match, software string
+	greetingVersion bool
+}{
+	{"dovecot", "Dovecot", false},
+	{"gpop", "Gmail", false},
+	{"microsoft exchange", "Microsoft Exchange", false},
+	{"qpopper", "Qpopper", true},
+	{"cyrus", "Cyrus", true},
+	{"courier", "Courier", false},
+	{"hello there.", "Courier", false},
+}
+
+var versionPattern = regexp.MustCompile(`\bv?(\d+\.\d+(?:\.\d+)*)\b`)
+
+// ServerInfo identifies the server software from the greeting and the
+// IMPLEMENTATION capability of RFC 2449, for diagnostics and for choosing
+// Quirks. Recognized are Dovecot, Gmail, Microsoft Exchange, Qpopper, Cyrus
+// and Courier. A negative response to CAPA is not an error.
+func (c *Client) ServerInfo() (info ServerInfo, err error) {
+	info.Greeting = c.greeting
+	caps, err := c.Caps()
+	if _, ok := err.(*Error); ok {
+		err = nil
+	} else if err != nil {
+		return info, err
+	}
+	for _, cp := range caps {
+		if fs := strings.SplitN(cp, " ", 2); len(fs) == 2 && strings.EqualFold(fs[0], "IMPLEMENTATION") {
+			info.Implementation = fs[1]
+		}
+	}
+
+	text := strings.ToLower(info.Implementation + " " + info.Greeting)
+	for _, sig := range serverSignatures {
+		if !strings.Contains(text, sig.match) {
+			continue
+		}
+		info.Software = sig.software
+		if m := versionPattern.FindStringSubmatch(info.Implementation); m != nil {
+			info.Version = m[1]
+		} else if m := versionPattern.FindStringSubmatch(info.Greeting); m != nil && sig.greetingVersion {
+			info.Version = m[1]
+		}
+		break
+	}
+	return info, nil
+}
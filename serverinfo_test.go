@@ -0,0 +1,34 @@
+package pop3
+
+import "testing"
+
+var serverInfoTests = []struct {
+	server            string
+	software, version string
+}{
+	{"+OK Dovecot (Ubuntu) ready.\n+OK\nUSER\n.\n", "Dovecot", ""},
+	{"+OK Gpop ready for requests from 203.0.113.7 a1mb123456wrt\n+OK\nUSER\n.\n", "Gmail", ""},
+	{"+OK The Microsoft Exchange POP3 service is ready.\n-ERR\n", "Microsoft Exchange", ""},
+	{"+OK Qpopper (version 4.1.0) at mail.example.com starting.\n+OK\nIMPLEMENTATION Qpopper-version-4.1.0\n.\n", "Qpopper", "4.1.0"},
+	{"+OK mail.example.com Cyrus POP3 v2.4.17 server ready <1896.697170952@mail.example.com>\n+OK\n.\n", "Cyrus", "2.4.17"},
+	{"+OK Hello there.\n+OK\nIMPLEMENTATION Courier Mail Server\n.\n", "Courier", ""},
+	{"+OK POP3 server ready\n+OK\n.\n", "", ""},
+}
+
+func TestServerInfo(t *testing.T) {
+	for _, tt := range serverInfoTests {
+		c, _ := fakeClient(t, tt.server)
+		info, err := c.ServerInfo()
+		if err != nil {
+			t.Fatalf("ServerInfo failed: %s", err)
+		}
+		if info.Greeting != c.Greeting() || info.Software != tt.software || info.Version != tt.version {
+			t.Fatalf("Got %+v, expected %s %s", info, tt.software, tt.version)
+		}
+	}
+
+	c, _ := fakeClient(t, "+OK Dovecot ready.\n")
+	if c.Greeting() != "Dovecot ready." {
+		t.Fatalf("Got greeting %q", c.Greeting())
+	}
+}
@@ -0,0 +1,200 @@
+package pop3
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A SessionManager polls a maildrop for new messages, as needed for mail
+// notifiers, while keeping to the minimum time between logins a server
+// announces with the LOGIN-DELAY capability of RFC 2449.
+//
+// Since a session only sees the messages present at login, every poll
+// starts a new one. The session of the last poll is kept alive with NOOP
+// until the next, so that the callback may keep using it, and is quit
+// before logging in again; deletions made in the meantime are committed
+// then. Note that most servers lock the maildrop while the session lasts.
+type SessionManager struct {
+	// Dial opens a new connection to the server.
+	Dial func() (*Client, error)
+
+	Username string
+	Password string
+
+	// KeepAlive is the interval at which NOOP is sent while a session is
+	// kept between polls. It should be well below the server's inactivity
+	// timeout, which RFC 1939 requires to be at least ten minutes.
+	KeepAlive time.Duration
+
+	mu         sync.Mutex
+	c          *Client
+	loginDelay time.Duration
+	lastLogin  time.Time
+	seen       map[string]bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSessionManager returns a SessionManager connecting with dial and
+// authenticating with Auth, which keeps sessions alive with a NOOP every
+// five minutes.
+func NewSessionManager(dial func() (*Client, error), username, password string) *SessionManager {
+	return &SessionManager{
+		Dial:      dial,
+		Username:  username,
+		Password:  password,
+		KeepAlive: 5 * time.Minute,
+		done:      make(chan struct{}),
+	}
+}
+
+// Poll logs in every interval, or less often if the server's login delay
+// requires it, and calls fn with the session and all messages not reported
+// before, which on the first poll are all messages in the maildrop. Messages
+// are told apart by unique-id, so the server must support UIDL; otherwise
+// Poll fails with ErrNoUIDL.
+//
+// Logins refused with the LOGIN-DELAY response code, and connection
+// failures, are retried at the next poll. Poll returns when Close is
+// called, or with the first other error, including any returned by fn.
+func (m *SessionManager) Poll(interval time.Duration, fn func(c *Client, msgs []MessageInfo) error) error {
+	var last time.Time
+	for {
+		m.mu.Lock()
+		next := last.Add(interval)
+		if t := m.lastLogin.Add(m.loginDelay); t.After(next) {
+			next = t
+		}
+		m.mu.Unlock()
+		if !m.wait(next) {
+			return nil
+		}
+		last = time.Now()
+
+		c, msgs, err := m.poll()
+		if err != nil {
+			if m.closed() {
+				return nil
+			}
+			if e, ok := err.(*Error); ok && e.Code == "LOGIN-DELAY" || isConnError(err) {
+				continue
+			}
+			return err
+		}
+		if len(msgs) > 0 {
+			if err = fn(c, msgs); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// poll ends the current session, logs in anew and returns the messages not
+// seen before.
+func (m *SessionManager) poll() (*Client, []MessageInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.c != nil {
+		m.c.Quit()
+		m.c = nil
+	}
+	c, err := m.Dial()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = c.Auth(m.Username, m.Password); err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	m.c = c
+	m.lastLogin = time.Now()
+	// After login, LOGIN-DELAY gives the delay for this user.
+	if caps, err := c.Caps(); err == nil {
+		if args, ok := capArgs(caps, "LOGIN-DELAY"); ok && len(args) > 0 {
+			if secs, err := strconv.Atoi(args[0]); err == nil {
+				m.loginDelay = time.Duration(secs) * time.Second
+			}
+		}
+	}
+
+	list, err := c.ListMessages()
+	if err != nil {
+		m.release()
+		return nil, nil, err
+	}
+	seen := make(map[string]bool, len(list))
+	var msgs []MessageInfo
+	for _, msg := range list {
+		if msg.UID == "" {
+			m.release()
+			return nil, nil, ErrNoUIDL
+		}
+		seen[msg.UID] = true
+		if !m.seen[msg.UID] {
+			msgs = append(msgs, msg)
+		}
+	}
+	m.seen = seen
+	return c, msgs, nil
+}
+
+// release quits the current session, so that it does not keep the maildrop
+// locked after a failed poll.
+func (m *SessionManager) release() {
+	m.c.Quit()
+	m.c = nil
+}
+
+// wait sleeps until t, sending NOOP on the current session every KeepAlive.
+// It returns false if Close is called meanwhile.
+func (m *SessionManager) wait(t time.Time) bool {
+	for {
+		d := time.Until(t)
+		if d <= 0 {
+			return !m.closed()
+		}
+		if m.KeepAlive > 0 && d > m.KeepAlive {
+			d = m.KeepAlive
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-m.done:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		if time.Now().Before(t) {
+			m.mu.Lock()
+			if m.c != nil && m.c.Noop() != nil {
+				m.c.Close()
+				m.c = nil
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+func (m *SessionManager) closed() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops Poll and quits the current session, if any, committing
+// deletions.
+func (m *SessionManager) Close() error {
+	m.closeOnce.Do(func() { close(m.done) })
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.c == nil {
+		return nil
+	}
+	err := m.c.Quit()
+	m.c = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package pop3
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionManager(t *testing.T) {
+	scripts := []string{sessionFirst, sessionDelayed, sessionSecond}
+	var cmdbufs []*bytes.Buffer
+	m := NewSessionManager(func() (*Client, error) {
+		c, cmdbuf := fakeClient(t, scripts[0])
+		scripts = scripts[1:]
+		cmdbufs = append(cmdbufs, cmdbuf)
+		return c, nil
+	}, "uname", "password")
+	m.KeepAlive = 5 * time.Millisecond
+
+	var polls [][]MessageInfo
+	start := time.Now()
+	err := m.Poll(10*time.Millisecond, func(c *Client, msgs []MessageInfo) error {
+		polls = append(polls, msgs)
+		if len(polls) == 2 {
+			m.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Poll failed: %s", err)
+	}
+	if len(polls) != 2 || len(polls[0]) != 1 || len(polls[1]) != 1 || polls[1][0].UID != "b" {
+		t.Fatalf("Got %+v", polls)
+	}
+	if len(scripts) != 0 {
+		t.Fatal("Poll did not retry the delayed login")
+	}
+	// The first session announced a login delay of one second.
+	if d := time.Since(start); d < time.Second {
+		t.Fatalf("Polled within %s", d)
+	}
+	if !bytes.Contains(cmdbufs[0].Bytes(), []byte("NOOP\r\n")) {
+		t.Fatalf("The first session was not kept alive: %q", cmdbufs[0].String())
+	}
+	if !bytes.HasSuffix(cmdbufs[2].Bytes(), []byte("QUIT\r\n")) {
+		t.Fatalf("Close did not quit the session: %q", cmdbufs[2].String())
+	}
+}
+
+func TestSessionManagerNoUIDL(t *testing.T) {
+	var cmdbuf *bytes.Buffer
+	m := NewSessionManager(func() (*Client, error) {
+		c, buf := fakeClient(t, "+OK good morning\n+OK\n.\n+OK send PASS\n+OK welcome\n+OK\n.\n"+
+			"+OK\n1 120\n.\n-ERR unknown command\n+OK bye\n")
+		cmdbuf = buf
+		return c, nil
+	}, "uname", "password")
+	err := m.Poll(time.Millisecond, func(*Client, []MessageInfo) error {
+		t.Fatal("Poll reported messages without unique-ids")
+		return nil
+	})
+	if err != ErrNoUIDL {
+		t.Fatalf("Poll returned %v, expected ErrNoUIDL", err)
+	}
+	if !bytes.HasSuffix(cmdbuf.Bytes(), []byte("UIDL\r\nQUIT\r\n")) {
+		t.Fatalf("The failed session was not quit: %q", cmdbuf.String())
+	}
+	if m.c != nil {
+		t.Fatal("The failed session was kept")
+	}
+}
+
+var sessionFirst = `+OK good morning
++OK
+LOGIN-DELAY 900
+.
++OK send PASS
++OK welcome
++OK
+LOGIN-DELAY 1
+UIDL
+.
++OK
+1 120
+.
++OK
+1 a
+.
+` + strings.Repeat("+OK\n", 500)
+
+var sessionDelayed = `+OK good morning
++OK
+.
++OK send PASS
+-ERR [LOGIN-DELAY] wait some more
+`
+
+var sessionSecond = `+OK good morning
++OK
+.
++OK send PASS
++OK welcome
++OK
+.
++OK
+1 120
+2 130
+.
++OK
+1 a
+2 b
+.
++OK bye
+`
@@ -0,0 +1,93 @@
+package pop3
+
+// A State is the state of a POP3 session, as described in RFC 1939.
+type State int
+
+const (
+	// StateAuthorization is the state after the greeting, until the client
+	// has identified itself successfully.
+	StateAuthorization State = iota
+	// StateTransaction is the state in which the maildrop may be accessed.
+	StateTransaction
+	// StateUpdate is the state after QUIT, while the server commits
+	// deletions.
+	StateUpdate
+	// StateClosed is the state after the connection has been closed, either
+	// after QUIT or because it failed.
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateAuthorization:
+		return "AUTHORIZATION"
+	case StateTransaction:
+		return "TRANSACTION"
+	case StateUpdate:
+		return "UPDATE"
+	case StateClosed:
+		return "CLOSED"
+	}
+	return "UNKNOWN"
+}
+
+const (
+	inAuthorization = 1 << StateAuthorization
+	inTransaction   = 1 << StateTransaction
+)
+
+// commandStates holds the states each command is valid in. Commands not
+// listed, such as extensions unknown to this package, are allowed in any
+// state but StateClosed, in which every command fails with ErrClosed.
+var commandStates = map[string]int{
+	"USER": inAuthorization,
+	"PASS": inAuthorization,
+	"APOP": inAuthorization,
+	"AUTH": inAuthorization,
+	"STLS": inAuthorization,
+	"UTF8": inAuthorization,
+	"STAT": inTransaction,
+	"LIST": inTransaction,
+	"RETR": inTransaction,
+	"DELE": inTransaction,
+	"NOOP": inTransaction,
+	"RSET": inTransaction,
+	"TOP":  inTransaction,
+	"UIDL": inTransaction,
+	"XTND": inTransaction,
+	"LANG": inAuthorization | inTransaction,
+	"CAPA": inAuthorization | inTransaction,
+	"QUIT": inAuthorization | inTransaction,
+}
+
+// State returns the current state of the session.
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// checkState returns an error if the command verb may not be issued in the
+// current state.
+func (c *Client) checkState(verb string) error {
+	if c.state == StateClosed {
+		return ErrClosed
+	}
+	if states, ok := commandStates[verb]; ok && states&(1<<c.state) == 0 {
+		return &StateError{Cmd: verb, State: c.state}
+	}
+	return nil
+}
+
+// closeConn closes the connection, ending the session.
+func (c *Client) closeConn() {
+	c.conn.Close()
+	c.state = StateClosed
+}
+
+// fail closes the connection after a network error, which is returned
+// wrapped in a ConnError.
+func (c *Client) fail(err error) error {
+	c.closeConn()
+	return &ConnError{Err: err}
+}
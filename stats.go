@@ -0,0 +1,95 @@
+package pop3
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// Stats holds counters describing the traffic of a Client, for exporting as
+// metrics.
+type Stats struct {
+	// BytesRead is the number of octets received from the server.
+	BytesRead int64
+	// BytesWritten is the number of octets sent to the server.
+	BytesWritten int64
+	// Commands holds the counters for each command issued, keyed by its
+	// name, such as "RETR".
+	Commands map[string]CommandStats
+}
+
+// CommandStats holds the counters for one command.
+type CommandStats struct {
+	// Count is the number of times the command was issued.
+	Count int64
+	// Errors is the number of times the command failed, whether the server
+	// rejected it or the connection failed.
+	Errors int64
+	// Latency is the total time spent waiting for the first line of the
+	// responses.
+	Latency time.Duration
+}
+
+// Stats returns a snapshot of the counters of the Client. It may be called
+// while a command is in progress.
+func (c *Client) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	s := c.stats
+	s.Commands = make(map[string]CommandStats, len(c.stats.Commands))
+	for k, v := range c.stats.Commands {
+		s.Commands[k] = v
+	}
+	return s
+}
+
+// WithCommandHook calls fn after every command has been answered or has
+// failed, with the name of the command, the time until the first line of
+// the response arrived and the resulting error, which is an *Error if the
+// server rejected the command. fn is called with the Client's lock held and
+// must not issue commands itself.
+func WithCommandHook(fn func(cmd string, rtt time.Duration, err error)) Option {
+	return func(c *Client) {
+		c.hook = fn
+	}
+}
+
+// record accounts for the command verb, answered with line after rtt.
+func (c *Client) record(verb string, rtt time.Duration, line string, err error) {
+	if verb == "" {
+		return
+	}
+	if err == nil && strings.HasPrefix(line, "-") {
+		err = c.newError(strings.TrimPrefix(line, "-ERR "))
+	}
+	c.statsMu.Lock()
+	if c.stats.Commands == nil {
+		c.stats.Commands = make(map[string]CommandStats)
+	}
+	cs := c.stats.Commands[verb]
+	cs.Count++
+	cs.Latency += rtt
+	if err != nil {
+		cs.Errors++
+	}
+	c.stats.Commands[verb] = cs
+	c.statsMu.Unlock()
+	if c.hook != nil {
+		c.hook(verb, rtt, err)
+	}
+}
+
+// countingReader counts the octets read from a connection in the Stats of
+// a Client.
+type countingReader struct {
+	r io.Reader
+	c *Client
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.c.statsMu.Lock()
+	r.c.stats.BytesRead += int64(n)
+	r.c.statsMu.Unlock()
+	return n, err
+}
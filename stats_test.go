@@ -0,0 +1,42 @@
+package pop3
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	var hooked []string
+	hook := WithCommandHook(func(cmd string, rtt time.Duration, err error) {
+		hooked = append(hooked, cmd)
+	})
+	c, cmdbuf := fakeSession(t, statsServer, hook)
+	c.Noop()
+	c.Dele(1)
+
+	s := c.Stats()
+	served := int64(len(strings.Replace(statsServer, "\n", "\r\n", -1)))
+	if s.BytesRead != served {
+		t.Fatalf("BytesRead is %d, expected %d", s.BytesRead, served)
+	}
+	if s.BytesWritten != int64(cmdbuf.Len()) {
+		t.Fatalf("BytesWritten is %d, expected %d", s.BytesWritten, cmdbuf.Len())
+	}
+	if cs := s.Commands["NOOP"]; cs.Count != 1 || cs.Errors != 0 {
+		t.Fatalf("Got %+v for NOOP", cs)
+	}
+	if cs := s.Commands["DELE"]; cs.Count != 1 || cs.Errors != 1 {
+		t.Fatalf("Got %+v for DELE", cs)
+	}
+	if len(hooked) != 4 {
+		t.Fatalf("Hook called for %q", hooked)
+	}
+}
+
+var statsServer = `+OK good morning
++OK send PASS
++OK welcome
++OK
+-ERR no such message
+`
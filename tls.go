@@ -0,0 +1,71 @@
+package pop3
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+)
+
+// StartTLS issues the STLS command defined in RFC 2595 and secures the
+// connection with TLS using config. It is only valid before authentication.
+// If config has no ServerName, verification fails unless InsecureSkipVerify
+// is set or a VerifyConnection callback accepts the certificate.
+func (c *Client) StartTLS(config *tls.Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.cmd("STLS")
+	if err != nil {
+		return err
+	}
+	tconn := tls.Client(c.conn, config)
+	if err = tconn.Handshake(); err != nil {
+		return c.fail(err)
+	}
+	c.setConn(tconn)
+	return nil
+}
+
+// SPKIFingerprint returns the pin of cert for use with PinSPKI: the
+// base64-encoded SHA-256 hash of its DER-encoded SubjectPublicKeyInfo.
+func SPKIFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// PinSPKI returns a copy of config which only accepts servers whose leaf
+// certificate matches one of the given pins, as returned by
+// SPKIFingerprint. Pins are checked in addition to the usual verification
+// against the root store and any VerifyConnection callback already set in
+// config; to rely on pins alone, for example with a self-signed
+// certificate, set InsecureSkipVerify as well.
+//
+// The returned config may be passed to DialTLS, DialTLSWithProxy and
+// StartTLS.
+func PinSPKI(config *tls.Config, pins ...string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("No server certificate")
+		}
+		fp := SPKIFingerprint(cs.PeerCertificates[0])
+		for _, pin := range pins {
+			if subtle.ConstantTimeCompare([]byte(fp), []byte(pin)) == 1 {
+				return nil
+			}
+		}
+		return errors.New("Server certificate does not match any pin")
+	}
+	return config
+}
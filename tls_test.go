@@ -0,0 +1,95 @@
+package pop3
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pop.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"pop.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestPinSPKI(t *testing.T) {
+	tlsCert, cert := selfSigned(t)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{tlsCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("+OK good morning\r\n"))
+			conn.Close()
+		}
+	}()
+
+	insecure := &tls.Config{InsecureSkipVerify: true}
+	c, err := DialTLS(l.Addr().String(), PinSPKI(insecure, "bm90IHRoZSBwaW4=", SPKIFingerprint(cert)))
+	if err != nil {
+		t.Fatalf("DialTLS with matching pin failed: %s", err)
+	}
+	c.Close()
+
+	if _, err = DialTLS(l.Addr().String(), PinSPKI(insecure, "bm90IHRoZSBwaW4=")); err == nil {
+		t.Fatal("DialTLS without matching pin succeeded")
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	tlsCert, cert := selfSigned(t)
+	client, server := net.Pipe()
+	go func() {
+		server.Write([]byte("+OK good morning\r\n"))
+		buf := make([]byte, 6)
+		server.Read(buf)
+		server.Write([]byte("+OK begin TLS negotiation\r\n"))
+		tserver := tls.Server(server, &tls.Config{Certificates: []tls.Certificate{tlsCert}})
+		tserver.Handshake()
+		tserver.Read(buf)
+		tserver.Write([]byte("+OK\r\n.\r\n"))
+	}()
+
+	c, err := NewClient(client)
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	if err = c.StartTLS(&tls.Config{ServerName: "pop.example.com", RootCAs: pool}); err != nil {
+		t.Fatalf("StartTLS failed: %s", err)
+	}
+	if _, err = c.Caps(); err != nil {
+		t.Fatalf("Caps over TLS failed: %s", err)
+	}
+}
@@ -0,0 +1,30 @@
+package pop3
+
+import (
+	"io"
+	"strings"
+)
+
+// WithTrace logs the protocol exchange to w. Every command sent is written
+// prefixed with "C: " and every line received prefixed with "S: ". Passwords
+// and authentication responses are masked; message contents are not. Errors
+// writing to w are ignored.
+func WithTrace(w io.Writer) Option {
+	return func(c *Client) {
+		c.trace = w
+	}
+}
+
+// redact masks the credentials in a command line.
+func redact(cmd string) string {
+	fs := strings.SplitN(strings.TrimRight(cmd, "\r\n"), " ", 3)
+	switch strings.ToUpper(fs[0]) {
+	case "PASS":
+		return fs[0] + " ********\r\n"
+	case "APOP", "AUTH":
+		if len(fs) == 3 {
+			return fs[0] + " " + fs[1] + " ********\r\n"
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,46 @@
+package pop3
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	server := strings.Join(strings.Split(traceServer, "\n"), "\r\n")
+	var cmdbuf, trace bytes.Buffer
+	var fake faker
+	fake.ReadWriter = struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(server), &cmdbuf}
+
+	c, err := NewClient(fake, WithTrace(&trace))
+	if err != nil {
+		t.Fatalf("NewClient failed: %s", err)
+	}
+	if err = c.User("uname"); err != nil {
+		t.Fatalf("User failed: %s", err)
+	}
+	if err = c.Pass("password1"); err != nil {
+		t.Fatalf("Pass failed: %s", err)
+	}
+
+	expected := strings.Join(strings.Split(traceExpected, "\n"), "\r\n")
+	if trace.String() != expected {
+		t.Fatalf("Got:\n%s\nExpected:\n%s", trace.String(), expected)
+	}
+}
+
+var traceServer = `+OK good morning
++OK send PASS
++OK welcome
+`
+
+var traceExpected = `S: +OK good morning
+C: USER uname
+S: +OK send PASS
+C: PASS ********
+S: +OK welcome
+`